@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"gitosint/pkg/git"
 	"log"
 	"os"
 	"time"
@@ -22,6 +23,19 @@ func (r *GitRecon) SetError(err error) {
 	}
 }
 
+// ConvertMetadata fills the output fields of the repository from its commit
+// metadata. Per-identity provenance is included only if requested.
+func (r *Repository) ConvertMetadata(provenance bool) {
+	r.Metadata = git.ConvertCommitMetadata(r.CommitMetadata)
+	r.Sources = git.ConvertSources(r.CommitMetadata)
+	r.Keys = git.ConvertKeys(r.CommitMetadata)
+	r.Clusters = git.ClusterIdentities(r.CommitMetadata)
+	r.Artifacts = git.ConvertArtifacts(r.CommitMetadata)
+	if provenance {
+		r.Identities = git.ConvertProvenance(r.CommitMetadata)
+	}
+}
+
 func SetOutput(path string) error {
 	if path != "" {
 		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
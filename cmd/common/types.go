@@ -12,10 +12,14 @@ type Error struct {
 
 type User struct {
 	Login         string   `json:"login,omitempty"`
+	ID            int64    `json:"id,omitempty"`
 	Name          string   `json:"name,omitempty"`
 	Type          string   `json:"type,omitempty"`
 	Organizations []string `json:"orgs,omitempty"`
 	Emails        []string `json:"emails,omitempty"`
+	// Status is the result of the lookup of an email: found, not-found or
+	// error.
+	Status string `json:"status,omitempty"`
 }
 
 type Repository struct {
@@ -24,9 +28,24 @@ type Repository struct {
 	Name           string              `json:"name,omitempty"`
 	Fork           *bool               `json:"fork,omitempty"`
 	Location       string              `json:"location,omitempty"`
+	Root           string              `json:"root,omitempty"`
+	Parent         string              `json:"parent,omitempty"`
 	Metadata       map[string][]string `json:"metadata,omitempty"`
+	Sources        map[string][]string `json:"sources,omitempty"`
+	Identities     []*git.Identity     `json:"identities,omitempty"`
+	Tags           []*git.Tag          `json:"tags,omitempty"`
+	Keys           []*git.SigningKey   `json:"keys,omitempty"`
+	Clusters       []*git.Cluster      `json:"clusters,omitempty"`
+	Config         *git.LeakedConfig   `json:"config,omitempty"`
+	Remote         *git.RemoteRefs     `json:"remote,omitempty"`
+	Secrets        []*git.Secret       `json:"secrets,omitempty"`
+	Documents      []*git.Document     `json:"documents,omitempty"`
+	Artifacts      *git.Artifacts      `json:"artifacts,omitempty"`
+	Anomalies      []*git.Anomaly      `json:"anomalies,omitempty"`
 	CommitMetadata git.CommitMetadata  `json:"-"`
 	Contributors   []*User             `json:"contributors,omitempty"`
+	// Deleted is set for scratch repositories deleted by github cleanup.
+	Deleted bool `json:"deleted,omitempty"`
 }
 
 type GitRecon struct {
@@ -0,0 +1,95 @@
+package dumpgit
+
+import (
+	"errors"
+	"fmt"
+	"gitosint/cmd/common"
+	pkggit "gitosint/pkg/git"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var opts options
+
+func NewCommand() *cobra.Command {
+	dumpCmd := &cobra.Command{
+		Use:   "dumpgit",
+		Short: "Recover repositories from .git directories exposed by web servers",
+		RunE:  dumpMain,
+	}
+
+	opts = options{}
+	opts.URLs = dumpCmd.Flags().StringSlice("urls", []string{}, "Comma-delimited base URLs of exposed .git directories")
+	opts.FURLs = dumpCmd.Flags().String("furls", "", "Newline-delimited base URLs of exposed .git directories")
+	opts.Threads = dumpCmd.Flags().Int("threads", 10, "Concurrent object downloads")
+	opts.Provenance = dumpCmd.Flags().Bool("provenance", false, "Include per-identity provenance (commits, refs, roles, dates)")
+	dumpCmd.Flags().SortFlags = false
+	return dumpCmd
+}
+
+func (o options) validate() error {
+	if (len(*o.URLs) != 0 && *o.FURLs != "") || (len(*o.URLs) == 0 && *o.FURLs == "") {
+		return errors.New("specify either --urls or --furls")
+	}
+
+	if len(*o.URLs) == 0 && *o.FURLs != "" {
+		lines, err := common.ReadFile(*o.FURLs)
+		if err != nil {
+			return err
+		}
+		*o.URLs = lines
+	}
+
+	return nil
+}
+
+func dumpMain(cmd *cobra.Command, args []string) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	client := pkggit.NewHTTPClient()
+	for _, url := range *opts.URLs {
+		if ctx.Err() != nil {
+			break
+		}
+		record := &common.GitRecon{
+			Time:       time.Now(),
+			Repository: &common.Repository{Location: url},
+		}
+
+		result, err := pkggit.DumpRepo(ctx, client, url, *opts.Threads)
+		if err != nil {
+			record.SetError(fmt.Errorf("failed to dump '%s': (%s)", url, err.Error()))
+			if err := record.Write(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		record.Repository.Config = result.Config
+		if result.Missing != 0 {
+			record.SetError(fmt.Errorf("%d objects of '%s' could not be recovered", result.Missing, url))
+		}
+
+		metadata, err := pkggit.CollectMetadata(result.Repo)
+		if err != nil {
+			record.SetError(fmt.Errorf("failed to extract metadata for '%s': (%s)", url, err.Error()))
+			if err := record.Write(); err != nil {
+				return err
+			}
+			continue
+		}
+		record.Repository.CommitMetadata = metadata
+		record.Repository.Tags, err = pkggit.CollectTags(result.Repo)
+		record.SetError(err)
+		record.Repository.ConvertMetadata(*opts.Provenance)
+		if err := record.Write(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,8 @@
+package dumpgit
+
+type options struct {
+	URLs       *[]string
+	FURLs      *string
+	Threads    *int
+	Provenance *bool
+}
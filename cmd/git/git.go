@@ -2,6 +2,7 @@ package git
 
 import (
 	"errors"
+	"fmt"
 	"gitosint/cmd/common"
 	pkggit "gitosint/pkg/git"
 	"os"
@@ -11,7 +12,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var opts options
+var (
+	opts        options
+	secretRules = pkggit.DefaultRules
+)
 
 func NewCommand() *cobra.Command {
 	analyseCmd := &cobra.Command{
@@ -28,7 +32,22 @@ func NewCommand() *cobra.Command {
 	opts.GitRepos = analyseCmd.Flags().StringSlice("repos", []string{}, "Comma-delimited locations of Git repositories")
 	opts.FGitRepos = analyseCmd.Flags().String("frepos", "", "Newline-delimited locations of Git repositories")
 	opts.Local = analyseCmd.Flags().Bool("local", false, "Specify whether repository being analyzed is local")
+	opts.Discover = analyseCmd.Flags().Bool("discover", false, "Recursively discover repositories under the local directories")
+	opts.LsRemote = analyseCmd.Flags().Bool("ls-remote", false, "Only list references and access status of remote repositories, without cloning")
 	opts.Threads = analyseCmd.Flags().Int("threads", 10, "Concurrent cloning")
+	opts.Cache = analyseCmd.Flags().String("cache", "", "Directory of cloned repositories, fetched and rescanned incrementally on later runs")
+	opts.PullRefs = analyseCmd.Flags().Bool("pull-refs", false, "Also fetch GitHub pull request refs (refs/pull/*/head) of remote repositories")
+	opts.Submodules = analyseCmd.Flags().Bool("submodules", false, "Clone and analyze submodules of remote repositories")
+	opts.SubmoduleDepth = analyseCmd.Flags().Int("submodule-depth", 1, "Maximum depth of nested submodules")
+	opts.Provenance = analyseCmd.Flags().Bool("provenance", false, "Include per-identity provenance (commits, refs, roles, dates)")
+	opts.Deep = analyseCmd.Flags().Bool("deep", false, "Also mine reflogs, stashes and unreachable commits of local repositories, requires --local or --discover")
+	opts.Secrets = analyseCmd.Flags().Bool("secrets", false, "Scan commit history for secrets and credentials")
+	opts.SecretRules = analyseCmd.Flags().String("secret-rules", "", "JSON file with secret rules replacing the built-in ones")
+	opts.Files = analyseCmd.Flags().Bool("files", false, "Extract identities from AUTHORS, manifests and copyright headers")
+	opts.FilesHistory = analyseCmd.Flags().Bool("files-history", false, "Extract identities from files of every commit (implies --files)")
+	opts.Documents = analyseCmd.Flags().Bool("documents", false, "Extract metadata of PDF, Office documents and images from commit history")
+	opts.Messages = analyseCmd.Flags().Bool("messages", false, "Extract internal hosts, private IPs, ticket keys, URLs and emails from commit messages")
+	opts.Anomalies = analyseCmd.Flags().Bool("anomalies", false, "Report spoofed or rewritten history (committer mismatches, forged dates, unsigned commits)")
 	analyseCmd.Flags().SortFlags = false
 	return analyseCmd
 }
@@ -47,6 +66,32 @@ func (o options) validate() error {
 		o.GitRepos = &lines
 	}
 
+	if *o.LsRemote && (*o.Local || *o.Discover) {
+		return errors.New("--ls-remote cannot be used with --local or --discover")
+	}
+
+	// clones only hold what was fetched, and cached rescans would report the
+	// history of earlier runs as unreachable
+	if *o.Deep && !*o.Local && !*o.Discover {
+		return errors.New("--deep can only be used with --local or --discover")
+	}
+
+	pkggit.SetFetchPullRefs(*o.PullRefs)
+
+	if *o.Cache != "" {
+		if err := pkggit.SetCacheDir(*o.Cache); err != nil {
+			return err
+		}
+	}
+
+	if *o.SecretRules != "" {
+		rules, err := pkggit.LoadRules(*o.SecretRules)
+		if err != nil {
+			return err
+		}
+		secretRules = rules
+	}
+
 	if *o.Username != "" && *o.PassPrompt {
 		templates := &promptui.PromptTemplates{
 			Prompt:  "{{ . }}",
@@ -87,34 +132,129 @@ func analyseMain(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	ctx := cmd.Context()
+	if *opts.LsRemote {
+		for result := range pkggit.ListRemotes(ctx, *opts.GitRepos, *opts.Threads) {
+			record := &common.GitRecon{
+				Time: time.Now(),
+				Repository: &common.Repository{
+					Location: result.Origin,
+					Remote:   result.Refs,
+				},
+			}
+			record.SetError(result.Error)
+			record.Write()
+		}
+		return nil
+	}
+
 	var openResult []pkggit.OpenResult
-	if !*opts.Local {
-		for result := range pkggit.CloneRepos(*opts.GitRepos, *opts.Threads) {
+	if *opts.Discover {
+		for _, root := range *opts.GitRepos {
+			openResult = append(openResult, pkggit.DiscoverRepos(root)...)
+		}
+	} else if !*opts.Local && *opts.Submodules {
+		for result := range pkggit.CloneReposRecursive(ctx, *opts.GitRepos, *opts.Threads, *opts.SubmoduleDepth) {
+			openResult = append(openResult, result)
+		}
+	} else if !*opts.Local {
+		for result := range pkggit.CloneRepos(ctx, *opts.GitRepos, *opts.Threads) {
 			openResult = append(openResult, result)
 		}
 	} else {
 		openResult = pkggit.OpenRepos(*opts.GitRepos)
 	}
 
+	tempDirs := make(map[string]struct{})
+	for _, result := range openResult {
+		if result.TempDir != "" {
+			tempDirs[result.TempDir] = struct{}{}
+		}
+	}
+	defer func() {
+		for dir := range tempDirs {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	var err error
 	for _, result := range openResult {
+		if ctx.Err() != nil {
+			break
+		}
 		record := &common.GitRecon{
-			Time:       time.Now(),
-			Repository: &common.Repository{Location: result.Origin},
+			Time: time.Now(),
+			Repository: &common.Repository{
+				Location: result.Origin,
+				Root:     result.Root,
+				Parent:   result.Parent,
+			},
 		}
 		if result.Error != nil {
 			record.SetError(result.Error)
 			record.Write()
 			continue
 		}
+		if result.SubmoduleError != nil {
+			record.SetError(fmt.Errorf("failed to read submodules: (%s)", result.SubmoduleError.Error()))
+		}
 
-		metadata, err := pkggit.CollectMetadata(result.Repo)
+		// remote repositories of the cache are rescanned incrementally
+		var state *pkggit.CacheState
+		if pkggit.IsCached() && !*opts.Local && !*opts.Discover {
+			state, err = pkggit.LoadCacheState(result.Origin)
+			if err != nil {
+				record.SetError(err)
+				record.Write()
+				continue
+			}
+		}
+
+		var metadata pkggit.CommitMetadata
+		if state != nil {
+			metadata, err = pkggit.CollectNewMetadata(result.Repo, state)
+		} else {
+			metadata, err = pkggit.CollectMetadata(result.Repo)
+		}
 		if err != nil {
 			record.SetError(err)
 			record.Write()
 			continue
 		}
+		if *opts.Deep {
+			if err := pkggit.CollectUnreachable(result.Repo, metadata); err != nil {
+				record.SetError(fmt.Errorf("failed to collect unreachable commits: (%s)", err.Error()))
+			}
+		}
+
+		if *opts.Files || *opts.FilesHistory {
+			if err := pkggit.CollectFileIdentities(result.Repo, metadata, *opts.FilesHistory); err != nil {
+				record.SetError(fmt.Errorf("failed to extract identities from files: (%s)", err.Error()))
+			}
+		}
 
-		record.Repository.Metadata = pkggit.ConvertCommitMetadata(metadata)
+		record.Repository.CommitMetadata = metadata
+		record.Repository.Tags, err = pkggit.CollectTags(result.Repo)
+		record.SetError(err)
+		if *opts.Secrets {
+			record.Repository.Secrets, err = pkggit.ScanSecrets(result.Repo, metadata, secretRules)
+			record.SetError(err)
+		}
+		if *opts.Messages {
+			pkggit.CollectMessageArtifacts(result.Repo, metadata)
+		}
+		if *opts.Anomalies {
+			record.Repository.Anomalies = pkggit.DetectAnomalies(result.Repo, metadata)
+		}
+		if *opts.Documents {
+			record.Repository.Documents, err = pkggit.CollectDocuments(result.Repo, metadata)
+			record.SetError(err)
+		}
+		if state != nil {
+			state.KeepNew(metadata)
+			record.SetError(state.Save())
+		}
+		record.Repository.ConvertMetadata(*opts.Provenance)
 		record.Write()
 	}
 
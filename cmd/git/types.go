@@ -1,12 +1,27 @@
 package git
 
 type options struct {
-	GitRepos   *[]string
-	FGitRepos  *string
-	Local      *bool
-	Username   *string
-	Token      *string
-	SshKeyPath *string
-	PassPrompt *bool
-	Threads    *int
+	GitRepos       *[]string
+	FGitRepos      *string
+	Local          *bool
+	Discover       *bool
+	Username       *string
+	Token          *string
+	SshKeyPath     *string
+	PassPrompt     *bool
+	Threads        *int
+	Submodules     *bool
+	SubmoduleDepth *int
+	Provenance     *bool
+	Deep           *bool
+	Secrets        *bool
+	SecretRules    *string
+	Files          *bool
+	FilesHistory   *bool
+	Documents      *bool
+	Messages       *bool
+	Anomalies      *bool
+	Cache          *string
+	LsRemote       *bool
+	PullRefs       *bool
 }
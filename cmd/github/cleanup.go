@@ -0,0 +1,84 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gitosint/cmd/common"
+	"gitosint/pkg/github"
+)
+
+var cleanupOpts cleanupOptions
+
+func newCleanupCommand() *cobra.Command {
+	cleanupCmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Delete scratch repositories left by email lookups",
+		RunE:  cleanupMain,
+	}
+
+	cleanupCmd.Flags().SortFlags = false
+	cleanupOpts = cleanupOptions{}
+	cleanupOpts.DryRun = cleanupCmd.Flags().Bool("dry-run", false, "List the scratch repositories without deleting them")
+	cleanupOpts.MinAge = cleanupCmd.Flags().Duration("min-age", time.Hour, "Skip scratch repositories created more recently, they may be used by running lookups")
+
+	return cleanupCmd
+}
+
+func cleanupMain(cmd *cobra.Command, args []string) error {
+	if *opts.Token == "" {
+		return errors.New("specify -t/--token")
+	}
+
+	ctx := cmd.Context()
+	client, err := github.NewClient(*opts.Token, *opts.BaseURL, *opts.UploadURL)
+	if err != nil {
+		return fmt.Errorf("invalid client: (%s)", err)
+	}
+
+	currentUser, err := client.GetUserOrOrganization(ctx, "")
+	if err != nil {
+		return fmt.Errorf("invalid token: (%s)", err)
+	}
+	owner, ownerType := *opts.ScratchOwner, "Organization"
+	if owner == "" || strings.EqualFold(owner, currentUser.GetLogin()) {
+		owner, ownerType = currentUser.GetLogin(), "User"
+	}
+
+	repos, _, err := client.ListScratchRepositories(ctx, owner, ownerType)
+	if err != nil {
+		return fmt.Errorf("failed to list scratch repositories: (%s)", err)
+	}
+
+	for _, repo := range repos {
+		if ctx.Err() != nil {
+			break
+		}
+		if time.Since(repo.GetCreatedAt().Time) < *cleanupOpts.MinAge {
+			continue
+		}
+		record := &common.GitRecon{
+			Time: time.Now(),
+			Repository: &common.Repository{
+				Owner:    repo.GetOwner().GetLogin(),
+				Name:     repo.GetName(),
+				Location: repo.GetHTMLURL(),
+			},
+		}
+		if !*cleanupOpts.DryRun {
+			err := client.DeleteRepository(ctx, repo)
+			if err != nil {
+				record.SetError(fmt.Errorf("failed to delete '%s': (%s)", repo.GetHTMLURL(), err.Error()))
+			}
+			record.Repository.Deleted = err == nil
+		}
+		if err := record.Write(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
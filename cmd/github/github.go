@@ -3,6 +3,7 @@ package github
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -11,7 +12,10 @@ import (
 	"gitosint/pkg/github"
 )
 
-var opts options
+var (
+	opts        options
+	secretRules = git.DefaultRules
+)
 
 func NewCommand() *cobra.Command {
 	githubCmd := &cobra.Command{
@@ -23,7 +27,7 @@ func NewCommand() *cobra.Command {
 
 	githubCmd.Flags().SortFlags = false
 	opts = options{}
-	opts.Token = githubCmd.Flags().StringP("token", "t", "", "GitHub authentication token (required)")
+	opts.Token = githubCmd.PersistentFlags().StringP("token", "t", "", "GitHub authentication token (required)")
 	opts.Rate = githubCmd.Flags().Bool("rate", false, "Rate limits of the current token")
 	opts.Users = githubCmd.Flags().StringSlice("users", []string{}, "Comma-delimited list of usernames")
 	opts.Fusers = githubCmd.Flags().String("fusers", "", "File with newline-delimited list of usernames")
@@ -36,11 +40,30 @@ func NewCommand() *cobra.Command {
 	opts.Pulls = githubCmd.Flags().Bool("pulls", false, "Include pull requests")
 	opts.Members = githubCmd.Flags().Bool("members", false, "Analyze organization members")
 	opts.Contributors = githubCmd.Flags().Bool("contributors", false, "Include repository contributors")
+	opts.PullRefs = githubCmd.Flags().Bool("pull-refs", false, "Fetch pull request commits over git (refs/pull/*/head) instead of the API, cannot be used with --pulls")
 	opts.MaxPullRequests = githubCmd.Flags().Int("max-pulls", 0, "Maximum number of pull requests")
-	opts.BaseURL = githubCmd.Flags().String("baseurl", "https://api.github.com/", "GitHub Base API URL")
-	opts.UploadURL = githubCmd.Flags().String("uploadurl", "https://uploads.github.com/", "GitHub Upload API URL")
+	opts.ForkNetwork = githubCmd.Flags().Bool("fork-network", false, "Harvest identities from commits of forks that are not in the analyzed repositories")
+	opts.MaxForks = githubCmd.Flags().Int("max-forks", 50, "Maximum number of forks per repository (0 for all)")
+	opts.ForkDepth = githubCmd.Flags().Int("fork-depth", 1, "Maximum depth of forks of forks")
+	opts.BaseURL = githubCmd.PersistentFlags().String("baseurl", "https://api.github.com/", "GitHub Base API URL")
+	opts.UploadURL = githubCmd.PersistentFlags().String("uploadurl", "https://uploads.github.com/", "GitHub Upload API URL")
+	opts.ScratchOwner = githubCmd.PersistentFlags().String("scratch-owner", "", "Organization owning the scratch repositories of email lookups (default: authenticated user)")
+	opts.ScratchReuse = githubCmd.Flags().Bool("scratch-reuse", false, "Reuse a pool of up to --batch-threads scratch repositories for email lookups, force-pushing each batch")
+	opts.BatchThreads = githubCmd.Flags().Int("batch-threads", 4, "Concurrent batches of email lookups, reduced when the core rate limit runs low")
 	opts.Threads = githubCmd.Flags().Int("threads", 10, "Concurrent cloning")
+	opts.Cache = githubCmd.Flags().String("cache", "", "Directory of cloned repositories, fetched and rescanned incrementally on later runs")
+	opts.Submodules = githubCmd.Flags().Bool("submodules", false, "Clone and analyze submodules of the repositories")
+	opts.SubmoduleDepth = githubCmd.Flags().Int("submodule-depth", 1, "Maximum depth of nested submodules")
+	opts.Provenance = githubCmd.Flags().Bool("provenance", false, "Include per-identity provenance (commits, refs, roles, dates)")
+	opts.Secrets = githubCmd.Flags().Bool("secrets", false, "Scan commit history of the repositories for secrets and credentials")
+	opts.SecretRules = githubCmd.Flags().String("secret-rules", "", "JSON file with secret rules replacing the built-in ones")
+	opts.Files = githubCmd.Flags().Bool("files", false, "Extract identities from AUTHORS, manifests and copyright headers")
+	opts.FilesHistory = githubCmd.Flags().Bool("files-history", false, "Extract identities from files of every commit (implies --files)")
+	opts.Documents = githubCmd.Flags().Bool("documents", false, "Extract metadata of PDF, Office documents and images from commit history")
+	opts.Messages = githubCmd.Flags().Bool("messages", false, "Extract internal hosts, private IPs, ticket keys, URLs and emails from commit messages")
+	opts.Anomalies = githubCmd.Flags().Bool("anomalies", false, "Report spoofed or rewritten history (committer mismatches, forged dates, unsigned commits)")
 
+	githubCmd.AddCommand(newCleanupCommand())
 	return githubCmd
 }
 
@@ -49,27 +72,31 @@ func githubMain(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	ctx := cmd.Context()
 	client, err := github.NewClient(*opts.Token, *opts.BaseURL, *opts.UploadURL)
 	if err != nil {
 		return fmt.Errorf("invalid client: (%s)", err)
 	}
 
-	currentUser, err := client.GetUserOrOrganization("")
+	currentUser, err := client.GetUserOrOrganization(ctx, "")
 	if err != nil {
 		return fmt.Errorf("invalid token: (%s)", err)
 	}
 	gUser := currentUser.GetLogin()
 	git.SetBasicAuth(gUser, *opts.Token)
+	if strings.EqualFold(*opts.ScratchOwner, gUser) {
+		*opts.ScratchOwner = ""
+	}
 
 	if *opts.Rate {
-		limits, _, err := client.RateLimits()
+		limits, _, err := client.RateLimits(ctx)
 		if err != nil {
 			return err
 		}
 		common.PrintJSON(limits)
 	} else if len(*opts.Emails) != 0 {
 		output := make(chan *common.GitRecon)
-		go bulkUserSearch(client, *opts.Emails, output)
+		go bulkUserSearch(ctx, client, *opts.Emails, output)
 		for record := range output {
 			if err := record.Write(); err != nil {
 				return err
@@ -77,8 +104,11 @@ func githubMain(cmd *cobra.Command, args []string) error {
 		}
 		if *opts.Search {
 			for _, email := range *opts.Emails {
-				for _, record := range searchCommits(client, email) {
-					record.Repository.Metadata = git.ConvertCommitMetadata(record.Repository.CommitMetadata)
+				if ctx.Err() != nil {
+					break
+				}
+				for _, record := range searchCommits(ctx, client, email) {
+					record.Repository.ConvertMetadata(*opts.Provenance)
 					if err := record.Write(); err != nil {
 						return err
 					}
@@ -87,7 +117,7 @@ func githubMain(cmd *cobra.Command, args []string) error {
 		}
 	} else if len(*opts.Users) != 0 {
 		output := make(chan *common.GitRecon)
-		go bulkUserAnalysis(client, *opts.Users, output)
+		go bulkUserAnalysis(ctx, client, *opts.Users, output)
 
 		for record := range output {
 			if err := record.Write(); err != nil {
@@ -96,7 +126,7 @@ func githubMain(cmd *cobra.Command, args []string) error {
 		}
 	} else if len(*opts.Repos) != 0 {
 		output := make(chan *common.GitRecon)
-		go bulkRepoAnalysis(client, *opts.Repos, output)
+		go bulkRepoAnalysis(ctx, client, *opts.Repos, output)
 
 		for record := range output {
 			if err := record.Write(); err != nil {
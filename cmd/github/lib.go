@@ -1,6 +1,7 @@
 package github
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"gitosint/cmd/common"
@@ -8,8 +9,11 @@ import (
 	"gitosint/pkg/github"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	gh "github.com/google/go-github/v35/github"
 )
 
@@ -30,6 +34,30 @@ func (o options) validate() error {
 		return errors.New("use either --repos or --frepos")
 	}
 
+	if *opts.Pulls && *opts.PullRefs {
+		return errors.New("use either --pulls or --pull-refs")
+	}
+
+	if *opts.BatchThreads < 1 {
+		return errors.New("--batch-threads must be at least 1")
+	}
+
+	git.SetFetchPullRefs(*opts.PullRefs)
+
+	if *opts.Cache != "" {
+		if err := git.SetCacheDir(*opts.Cache); err != nil {
+			return err
+		}
+	}
+
+	if *opts.SecretRules != "" {
+		rules, err := git.LoadRules(*opts.SecretRules)
+		if err != nil {
+			return err
+		}
+		secretRules = rules
+	}
+
 	if len(*opts.Users) == 0 && *opts.Fusers != "" {
 		lines, err := common.ReadFile(*opts.Fusers)
 		if err != nil {
@@ -53,10 +81,11 @@ func (o options) validate() error {
 	return nil
 }
 
-func bulkUserAnalysis(client *github.Client, users []string, out chan<- *common.GitRecon) {
+func bulkUserAnalysis(ctx context.Context, client *github.Client, users []string, out chan<- *common.GitRecon) {
+	defer close(out)
 	var err error = nil
 	var gUser *gh.User
-	gUser, err = client.GetUserOrOrganization("")
+	gUser, err = client.GetUserOrOrganization(ctx, "")
 	if err != nil {
 		record := &common.GitRecon{}
 		record.SetError(fmt.Errorf("failed to get authenticated user: (%s)", err.Error()))
@@ -67,8 +96,11 @@ func bulkUserAnalysis(client *github.Client, users []string, out chan<- *common.
 	authUserLogin := gUser.GetLogin()
 
 	for _, user := range users {
-		if !client.IsGlobalRateLimitExceeded(err) {
-			gUser, err = client.GetUserOrOrganization(user)
+		if ctx.Err() != nil {
+			return
+		}
+		if !client.IsGlobalRateLimitExceeded(ctx, err) {
+			gUser, err = client.GetUserOrOrganization(ctx, user)
 		}
 		if err != nil {
 			record := &common.GitRecon{}
@@ -81,13 +113,11 @@ func bulkUserAnalysis(client *github.Client, users []string, out chan<- *common.
 			*gUser.Login = ""
 		}
 
-		analyseUser(client, gUser, out)
+		analyseUser(ctx, client, gUser, out)
 	}
-
-	close(out)
 }
 
-func analyseUser(client *github.Client, user *gh.User, out chan<- *common.GitRecon) {
+func analyseUser(ctx context.Context, client *github.Client, user *gh.User, out chan<- *common.GitRecon) {
 	var login string
 	if *user.Login == "" {
 		parsed := strings.Split(*user.HTMLURL, "/")
@@ -113,7 +143,7 @@ func analyseUser(client *github.Client, user *gh.User, out chan<- *common.GitRec
 		User: analysedUser,
 	}
 	if user.GetType() == "User" {
-		orgs, _, err := client.GetUserOrganizations(user.GetLogin())
+		orgs, _, err := client.GetUserOrganizations(ctx, user.GetLogin())
 		if err != nil {
 			record.SetError(fmt.Errorf("failed to get organizations for '%s': (%s)",
 				user.GetLogin(), err.Error()))
@@ -124,7 +154,7 @@ func analyseUser(client *github.Client, user *gh.User, out chan<- *common.GitRec
 			record.User.Organizations = append(record.User.Organizations, *org.Login)
 		}
 	} else {
-		orgMembers, _, err := client.ListOrganizationMembers(user.GetLogin())
+		orgMembers, _, err := client.ListOrganizationMembers(ctx, user.GetLogin())
 		if err != nil {
 			record.SetError(fmt.Errorf("failed to list organization's members for '%s': (%s)",
 				user.GetLogin(), err.Error()))
@@ -150,9 +180,12 @@ func analyseUser(client *github.Client, user *gh.User, out chan<- *common.GitRec
 		}
 
 		for _, member := range orgMembers {
+			if ctx.Err() != nil {
+				return
+			}
 			var ghUser *gh.User
-			if !client.IsGlobalRateLimitExceeded(err) {
-				ghUser, err = client.GetUserOrOrganization(member.GetLogin())
+			if !client.IsGlobalRateLimitExceeded(ctx, err) {
+				ghUser, err = client.GetUserOrOrganization(ctx, member.GetLogin())
 			}
 
 			if err != nil {
@@ -163,7 +196,7 @@ func analyseUser(client *github.Client, user *gh.User, out chan<- *common.GitRec
 			}
 
 			if *opts.Members {
-				analyseUser(client, ghUser, out)
+				analyseUser(ctx, client, ghUser, out)
 			} else {
 				record := memberRecordFunc(ghUser)
 				out <- record
@@ -171,7 +204,10 @@ func analyseUser(client *github.Client, user *gh.User, out chan<- *common.GitRec
 		}
 	}
 
-	repos, _, err := client.ListRepositories(user.GetLogin(), user.GetType(), *opts.Forks)
+	if ctx.Err() != nil {
+		return
+	}
+	repos, _, err := client.ListRepositories(ctx, user.GetLogin(), user.GetType(), *opts.Forks)
 	if err != nil {
 		record.SetError(fmt.Errorf("failed to list repositories for '%s': (%s)",
 			user.GetLogin(), err.Error()))
@@ -185,11 +221,11 @@ func analyseUser(client *github.Client, user *gh.User, out chan<- *common.GitRec
 	}
 
 	if user.GetType() == "User" && *opts.Search {
-		for _, record := range searchCommits(client, login) {
+		for _, record := range searchCommits(ctx, client, login) {
 			if _, ok := seenRepos[record.Repository.Location]; !ok {
 				record.User = analysedUser
 				if record.Repository.CommitMetadata != nil {
-					record.Repository.Metadata = git.ConvertCommitMetadata(record.Repository.CommitMetadata)
+					record.Repository.ConvertMetadata(*opts.Provenance)
 				}
 				out <- record
 			}
@@ -197,36 +233,42 @@ func analyseUser(client *github.Client, user *gh.User, out chan<- *common.GitRec
 	}
 
 	outCh := make(chan *common.GitRecon)
-	go analyseRepos(client, repos, outCh)
+	go analyseRepos(ctx, client, repos, outCh)
 	for record := range outCh {
 		record.User = analysedUser
 		out <- record
 	}
 }
 
-func bulkRepoAnalysis(client *github.Client, repos []string, out chan<- *common.GitRecon) {
-	gUser, err := client.GetUserOrOrganization("")
+func bulkRepoAnalysis(ctx context.Context, client *github.Client, repos []string, out chan<- *common.GitRecon) {
+	gUser, err := client.GetUserOrOrganization(ctx, "")
 	if err != nil {
 		record := &common.GitRecon{}
 		record.SetError(fmt.Errorf("failed to get authenticated user: (%s)", err.Error()))
 		out <- record
+		close(out)
 		return
 	}
 
 	var authUserRepos map[string]*gh.Repository
 	var ghRepos []*gh.Repository
 	for _, repo := range repos {
+		if ctx.Err() != nil {
+			close(out)
+			return
+		}
 		parsed := strings.Split(repo, "/")
 		owner := parsed[len(parsed)-2]
 		repo := parsed[len(parsed)-1]
 		if owner == *gUser.Login {
 			if authUserRepos == nil {
 				authUserRepos = make(map[string]*gh.Repository)
-				authRepos, _, err := client.ListRepositories("", *gUser.Type, true)
+				authRepos, _, err := client.ListRepositories(ctx, "", *gUser.Type, true)
 				if err != nil {
 					record := &common.GitRecon{}
 					record.SetError(fmt.Errorf("failed to get authenticated user repos: (%s)", err.Error()))
 					out <- record
+					close(out)
 					return
 				}
 				for _, authRepo := range authRepos {
@@ -238,7 +280,7 @@ func bulkRepoAnalysis(client *github.Client, repos []string, out chan<- *common.
 				ghRepos = append(ghRepos, r)
 			}
 		} else {
-			r, _, err := client.GetRepository(owner, repo)
+			r, _, err := client.GetRepository(ctx, owner, repo)
 			if err != nil {
 				record := &common.GitRecon{}
 				record.SetError(fmt.Errorf("failed to get repo '%s/%s': (%s)", owner, repo, err.Error()))
@@ -249,17 +291,22 @@ func bulkRepoAnalysis(client *github.Client, repos []string, out chan<- *common.
 		}
 	}
 
-	analyseRepos(client, ghRepos, out)
+	analyseRepos(ctx, client, ghRepos, out)
 }
 
-func analyseRepos(client *github.Client, repos []*gh.Repository, out chan<- *common.GitRecon) {
+func analyseRepos(ctx context.Context, client *github.Client, repos []*gh.Repository, out chan<- *common.GitRecon) {
 	// TODO: rewrite
 	defer close(out)
 	var err error = nil
 	var urls []string
 	urlToRepo := make(map[string]*common.GitRecon)
+	urlToGhRepo := make(map[string]*gh.Repository)
 	for _, repo := range repos {
+		if ctx.Err() != nil {
+			return
+		}
 		urls = append(urls, *repo.HTMLURL)
+		urlToGhRepo[*repo.HTMLURL] = repo
 		record := &common.GitRecon{
 			Repository: &common.Repository{
 				Owner:          *repo.Owner.Login,
@@ -273,8 +320,8 @@ func analyseRepos(client *github.Client, repos []*gh.Repository, out chan<- *com
 		if *opts.Pulls {
 			var pulls []*gh.PullRequest
 			var allCommits []*gh.CommitResult
-			if !client.IsGlobalRateLimitExceeded(err) {
-				pulls, _, err = client.ListPullRequests(repo, *opts.MaxPullRequests)
+			if !client.IsGlobalRateLimitExceeded(ctx, err) {
+				pulls, _, err = client.ListPullRequests(ctx, repo, *opts.MaxPullRequests)
 			}
 			if err != nil {
 				record.SetError(fmt.Errorf("failed to list pull requests for '%s': (%s)",
@@ -285,8 +332,8 @@ func analyseRepos(client *github.Client, repos []*gh.Repository, out chan<- *com
 				if pull.MergedAt != nil {
 					continue
 				}
-				if !client.IsGlobalRateLimitExceeded(err) {
-					commits, _, err := client.ListCommitsOnPullRequest(repo, *pull.Number)
+				if !client.IsGlobalRateLimitExceeded(ctx, err) {
+					commits, _, err := client.ListCommitsOnPullRequest(ctx, repo, *pull.Number)
 					if err == nil {
 						allCommits = append(allCommits, commits...)
 					}
@@ -309,8 +356,29 @@ func analyseRepos(client *github.Client, repos []*gh.Repository, out chan<- *com
 
 	recordCh := make(chan *common.GitRecon)
 	go func() {
-		for result := range git.CloneRepos(urls, *opts.Threads) {
-			record := urlToRepo[result.Origin]
+		var results <-chan git.OpenResult
+		if *opts.Submodules {
+			results = git.CloneReposRecursive(ctx, urls, *opts.Threads, *opts.SubmoduleDepth)
+		} else {
+			results = git.CloneRepos(ctx, urls, *opts.Threads)
+		}
+		for result := range results {
+			// interrupted, drop the rest and keep what is already sent
+			if ctx.Err() != nil {
+				os.RemoveAll(result.TempDir)
+				continue
+			}
+			record, ok := urlToRepo[result.Origin]
+			if !ok {
+				// submodule of an analysed repository
+				record = &common.GitRecon{
+					Repository: &common.Repository{
+						Location:       result.Origin,
+						Parent:         result.Parent,
+						CommitMetadata: make(git.CommitMetadata),
+					},
+				}
+			}
 			record.Time = time.Now()
 			if result.Error != nil {
 				record.SetError(fmt.Errorf("failed to clone '%s': (%s)",
@@ -318,38 +386,110 @@ func analyseRepos(client *github.Client, repos []*gh.Repository, out chan<- *com
 				recordCh <- record
 				continue
 			}
+			if result.SubmoduleError != nil {
+				record.SetError(fmt.Errorf("failed to read submodules of '%s': (%s)",
+					result.Origin, result.SubmoduleError.Error()))
+			}
 
-			metadata, err := git.CollectMetadata(result.Repo)
+			// cached repositories are rescanned incrementally
+			var state *git.CacheState
+			var metadata git.CommitMetadata
+			var err error
+			if git.IsCached() {
+				state, err = git.LoadCacheState(result.Origin)
+				if err == nil {
+					metadata, err = git.CollectNewMetadata(result.Repo, state)
+				}
+			} else {
+				metadata, err = git.CollectMetadata(result.Repo)
+			}
 			if err != nil {
 				record.SetError(fmt.Errorf("failed to extract metadata for '%s': (%s)",
-					result.Origin, result.Error.Error()))
+					result.Origin, err.Error()))
+				os.RemoveAll(result.TempDir)
 				recordCh <- record
 				continue
 			}
-			os.RemoveAll(result.Dir)
-			for k, v := range metadata {
-				record.Repository.CommitMetadata[k] = v
+			record.Repository.Tags, err = git.CollectTags(result.Repo)
+			if err != nil {
+				record.SetError(fmt.Errorf("failed to collect tags for '%s': (%s)",
+					result.Origin, err.Error()))
+			}
+			if *opts.Files || *opts.FilesHistory {
+				err = git.CollectFileIdentities(result.Repo, metadata, *opts.FilesHistory)
+				if err != nil {
+					record.SetError(fmt.Errorf("failed to extract identities from files of '%s': (%s)",
+						result.Origin, err.Error()))
+				}
+			}
+			if *opts.Secrets {
+				record.Repository.Secrets, err = git.ScanSecrets(result.Repo, metadata, secretRules)
+				if err != nil {
+					record.SetError(fmt.Errorf("failed to scan secrets for '%s': (%s)",
+						result.Origin, err.Error()))
+				}
+			}
+			if *opts.Messages {
+				git.CollectMessageArtifacts(result.Repo, metadata)
+			}
+			if *opts.Anomalies {
+				record.Repository.Anomalies = git.DetectAnomalies(result.Repo, metadata)
+			}
+			if *opts.Documents {
+				record.Repository.Documents, err = git.CollectDocuments(result.Repo, metadata)
+				if err != nil {
+					record.SetError(fmt.Errorf("failed to extract document metadata for '%s': (%s)",
+						result.Origin, err.Error()))
+				}
+			}
+			var forkRecords []*common.GitRecon
+			if ghRepo, ok := urlToGhRepo[result.Origin]; ok && *opts.ForkNetwork {
+				forkRecords = analyseForks(ctx, client, ghRepo, result.Repo)
+			}
+			os.RemoveAll(result.TempDir)
+			if state != nil {
+				state.KeepNew(metadata)
+				if err := state.Save(); err != nil {
+					record.SetError(fmt.Errorf("failed to save cache state for '%s': (%s)",
+						result.Origin, err.Error()))
+				}
 			}
+			record.Repository.CommitMetadata.Merge(metadata)
 			recordCh <- record
+			for _, forkRecord := range forkRecords {
+				recordCh <- forkRecord
+			}
 		}
 		close(recordCh)
 	}()
 
 	for record := range recordCh {
-		record.Repository.Metadata = git.ConvertCommitMetadata(record.Repository.CommitMetadata)
+		record.Repository.ConvertMetadata(*opts.Provenance)
 		if *opts.Contributors {
 			var emails []string
 			for email := range record.Repository.Metadata {
 				emails = append(emails, email)
 			}
 			outCh := make(chan *common.GitRecon)
-			go bulkUserSearch(client, emails, outCh)
+			go bulkUserSearch(ctx, client, emails, outCh)
+			// lookups are per email, contributors are per account and a
+			// failed batch reports the same error for each of its emails
+			logins := make(map[string]*common.User)
+			seenErrors := make(map[string]struct{})
 			for outRecord := range outCh {
-				if outRecord.User != nil {
+				if outRecord.User != nil && outRecord.User.Status == lookupFound {
+					if user, ok := logins[outRecord.User.Login]; ok {
+						user.Emails = append(user.Emails, outRecord.User.Emails...)
+						continue
+					}
+					logins[outRecord.User.Login] = outRecord.User
 					record.Repository.Contributors = append(record.Repository.Contributors, outRecord.User)
 				}
-				if outRecord.Error != nil {
-					record.Error = append(record.Error, outRecord.Error...)
+				for _, e := range outRecord.Error {
+					if _, ok := seenErrors[e.Message]; !ok {
+						seenErrors[e.Message] = struct{}{}
+						record.Error = append(record.Error, e)
+					}
 				}
 			}
 		}
@@ -357,79 +497,226 @@ func analyseRepos(client *github.Client, repos []*gh.Repository, out chan<- *com
 	}
 }
 
-func bulkUserSearch(client *github.Client, emails []string, out chan<- *common.GitRecon) {
+// analyseForks fetches the fork network of the repository into its clone,
+// breadth first up to the maximum depth and number of forks, and returns a
+// record per fork with the identities of commits missing upstream.
+func analyseForks(ctx context.Context, client *github.Client, repo *gh.Repository, clone *gogit.Repository) []*common.GitRecon {
+	var records []*common.GitRecon
+	upstream, err := git.NewUpstream(clone)
+	if err != nil {
+		record := &common.GitRecon{Time: time.Now()}
+		record.SetError(fmt.Errorf("failed to collect upstream history of '%s': (%s)",
+			*repo.HTMLURL, err.Error()))
+		return append(records, record)
+	}
+
+	level := []*gh.Repository{repo}
+	count := 0
+	for depth := 0; depth < *opts.ForkDepth && len(level) != 0; depth++ {
+		var next []*gh.Repository
+		for _, parent := range level {
+			if ctx.Err() != nil {
+				return records
+			}
+			remaining := 0
+			if *opts.MaxForks != 0 {
+				remaining = *opts.MaxForks - count
+				if remaining <= 0 {
+					return records
+				}
+			}
+			if !client.IsGlobalRateLimitExceeded(ctx, err) {
+				var forks []*gh.Repository
+				forks, _, err = client.ListForks(ctx, parent, remaining)
+				if err == nil {
+					next = append(next, forks...)
+					count += len(forks)
+					continue
+				}
+			}
+			record := &common.GitRecon{Time: time.Now()}
+			record.SetError(fmt.Errorf("failed to list forks of '%s': (%s)",
+				*parent.HTMLURL, err.Error()))
+			records = append(records, record)
+		}
+
+		for _, fork := range next {
+			if ctx.Err() != nil {
+				return records
+			}
+			record := &common.GitRecon{
+				Time: time.Now(),
+				Repository: &common.Repository{
+					Owner:    *fork.Owner.Login,
+					Location: *fork.HTMLURL,
+					Name:     *fork.Name,
+					Fork:     fork.Fork,
+					Parent:   *repo.HTMLURL,
+				},
+			}
+			record.Repository.CommitMetadata, err = git.CollectForkMetadata(ctx,
+				clone, upstream, *fork.CloneURL)
+			if err != nil {
+				record.SetError(fmt.Errorf("failed to fetch fork '%s': (%s)",
+					*fork.HTMLURL, err.Error()))
+				record.Repository.CommitMetadata = make(git.CommitMetadata)
+			}
+			records = append(records, record)
+		}
+		level = next
+	}
+	return records
+}
+
+func bulkUserSearch(ctx context.Context, client *github.Client, emails []string, out chan<- *common.GitRecon) {
 	defer close(out)
-	for i := 0; i < len(emails); i += 500 {
-		end := i + 500
+
+	// reused scratch repositories are shared by the batches that are not
+	// running at the same time and deleted at the end
+	pool := make(chan *gh.Repository, *opts.BatchThreads)
+	var scratch []*gh.Repository
+	defer func() {
+		for _, repo := range scratch {
+			client.DeleteRepository(context.Background(), repo)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{}, (len(emails)+lookupBatch-1)/lookupBatch)
+	running := 0
+	for i := 0; i < len(emails) && ctx.Err() == nil; i += lookupBatch {
+		end := i + lookupBatch
 		if end > len(emails) {
 			end = len(emails)
 		}
 
-		output := make(chan *common.GitRecon)
-		go searchUsers(client, emails[i:end], output)
+		for limit := batchLimit(ctx, client); running >= limit; running-- {
+			<-done
+		}
 
-		for record := range output {
-			out <- record
+		var repo *gh.Repository
+		if *opts.ScratchReuse {
+			select {
+			case repo = <-pool:
+			default:
+				var err error
+				repo, err = client.CreateRepository(ctx, *opts.ScratchOwner)
+				if err != nil {
+					lookupFailed(emails[i:end], fmt.Errorf("failed to create remote repo: (%s)", err.Error()), out)
+					continue
+				}
+				scratch = append(scratch, repo)
+			}
 		}
+
+		running++
+		wg.Add(1)
+		go func(emails []string, repo *gh.Repository) {
+			defer wg.Done()
+			output := make(chan *common.GitRecon)
+			go searchUsers(ctx, client, emails, repo, output)
+			for record := range output {
+				out <- record
+			}
+			if repo != nil {
+				pool <- repo
+			}
+			done <- struct{}{}
+		}(emails[i:end], repo)
 	}
+	wg.Wait()
 }
 
-func searchUsers(client *github.Client, emails []string, out chan<- *common.GitRecon) {
+// batchLimit returns how many lookup batches may run at the same time, at
+// most --batch-threads and fewer when the core rate limit runs low.
+func batchLimit(ctx context.Context, client *github.Client) int {
+	limits, _, err := client.RateLimits(ctx)
+	if err != nil || limits.GetCore() == nil {
+		return 1
+	}
+
+	limit := limits.GetCore().Remaining / batchRequests
+	if limit > *opts.BatchThreads {
+		limit = *opts.BatchThreads
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// searchUsers pushes the emails to the scratch repository and reports the
+// account each one is linked to, one record per email. A new scratch
+// repository is created if repo is nil, otherwise its history is replaced.
+func searchUsers(ctx context.Context, client *github.Client, emails []string, repo *gh.Repository,
+	out chan<- *common.GitRecon) {
 	defer close(out)
 	var err error = nil
 
-	record := &common.GitRecon{}
-	repo, err := client.CreateRepository()
-	if err != nil {
-		record.SetError(fmt.Errorf("failed to create remote repo: (%s)", err.Error()))
-		out <- record
-		return
+	reused := repo != nil
+	if !reused {
+		repo, err = client.CreateRepository(ctx, *opts.ScratchOwner)
+		if err != nil {
+			lookupFailed(emails, fmt.Errorf("failed to create remote repo: (%s)", err.Error()), out)
+			return
+		}
+		// the repository is deleted even if the context was cancelled
+		defer client.DeleteRepository(context.Background(), repo)
 	}
-	defer client.DeleteRepository(repo)
 
-	err = git.CreateRemoteRepo(emails, *repo.CloneURL)
+	err = git.CreateRemoteRepo(ctx, emails, *repo.CloneURL, reused)
 	if err != nil {
-		record.SetError(fmt.Errorf("failed to push emails to the remote repo: (%s)", err.Error()))
-		out <- record
+		lookupFailed(emails, fmt.Errorf("failed to push emails to the remote repo: (%s)", err.Error()), out)
 		return
 	}
 	// ensure repo is created
-	time.Sleep(2 * time.Second)
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(2 * time.Second):
+	}
 
-	contributors, _, err := client.ListContributors(repo)
+	authors, _, err := client.ListCommitAuthors(ctx, repo)
 	if err != nil {
-		record.SetError(fmt.Errorf("failed to list contributors: (%s)", err.Error()))
-		out <- record
+		lookupFailed(emails, fmt.Errorf("failed to list commits: (%s)", err.Error()), out)
 		return
 	}
 
-	var contribEmails []string
-	for _, contributor := range contributors {
-		record = &common.GitRecon{Time: time.Now()}
-		user := &common.User{Login: contributor.GetLogin()}
-		record.User = user
-		if !client.IsGlobalRateLimitExceeded(err) {
-			contribEmails, _, err = client.ListEmails(repo, contributor)
+	for _, email := range emails {
+		record := &common.GitRecon{
+			Time: time.Now(),
+			User: &common.User{Emails: []string{email}, Status: lookupNotFound},
 		}
-		if err != nil {
-			record.SetError(fmt.Errorf("failed to list emails for '%s': (%s)",
-				contributor.GetLogin(), err.Error()))
-			out <- record
-			continue
+		user, ok := authors[email]
+		if !ok {
+			record.User.Status = lookupError
+			record.SetError(fmt.Errorf("commit of '%s' is missing from the remote repo", email))
+		} else if user != nil {
+			record.User.Login = user.GetLogin()
+			record.User.ID = user.GetID()
+			record.User.Type = user.GetType()
+			record.User.Status = lookupFound
 		}
+		out <- record
+	}
+}
 
-		record.User.Emails = contribEmails
+// lookupFailed reports the lookup of every email as failed.
+func lookupFailed(emails []string, err error, out chan<- *common.GitRecon) {
+	for _, email := range emails {
+		record := &common.GitRecon{User: &common.User{Emails: []string{email}, Status: lookupError}}
+		record.SetError(err)
 		out <- record
 	}
 }
 
-func searchCommits(client *github.Client, loginOrEmail string) []*common.GitRecon {
+func searchCommits(ctx context.Context, client *github.Client, loginOrEmail string) []*common.GitRecon {
 	var records []*common.GitRecon
 	allRepos := make(map[int64]*gh.Repository)
 	allMetadata := make(map[int64]git.CommitMetadata)
 
 	for _, i := range []string{"author", "committer"} {
-		commits, _, err := client.SearchCommits(loginOrEmail, i)
+		commits, _, err := client.SearchCommits(ctx, loginOrEmail, i)
 		if err != nil {
 			record := &common.GitRecon{}
 			record.SetError(fmt.Errorf("failed to search %s commits: (%s)", i, err.Error()))
@@ -439,9 +726,7 @@ func searchCommits(client *github.Client, loginOrEmail string) []*common.GitReco
 		metadata, repos := processCommits(commits, i)
 		for id, gitmeta := range metadata {
 			if _, ok := allMetadata[id]; ok {
-				for k, v := range gitmeta {
-					allMetadata[id][k] = v
-				}
+				allMetadata[id].Merge(gitmeta)
 			} else {
 				allMetadata[id] = gitmeta
 			}
@@ -481,22 +766,22 @@ func processCommits(commits []*gh.CommitResult, usertype string) (map[int64]git.
 
 		metadata[id] = make(git.CommitMetadata)
 		if usertype == "author" {
-			metadata[id][git.Metadata{
-				Email: *commit.Commit.Author.Email,
-				Name:  *commit.Commit.Author.Name}] = struct{}{}
+			metadata[id].Add(signature(commit.Commit.Author), git.RoleAuthor, commit.GetSHA())
 		} else if usertype == "committer" {
-			metadata[id][git.Metadata{
-				Email: *commit.Commit.Committer.Email,
-				Name:  *commit.Commit.Committer.Name}] = struct{}{}
+			metadata[id].Add(signature(commit.Commit.Committer), git.RoleCommitter, commit.GetSHA())
 		} else {
-			metadata[id][git.Metadata{
-				Email: *commit.Commit.Author.Email,
-				Name:  *commit.Commit.Author.Name}] = struct{}{}
-			metadata[id][git.Metadata{
-				Email: *commit.Commit.Committer.Email,
-				Name:  *commit.Commit.Committer.Name}] = struct{}{}
+			metadata[id].Add(signature(commit.Commit.Author), git.RoleAuthor, commit.GetSHA())
+			metadata[id].Add(signature(commit.Commit.Committer), git.RoleCommitter, commit.GetSHA())
 		}
 	}
 
 	return metadata, repos
 }
+
+func signature(author *gh.CommitAuthor) object.Signature {
+	return object.Signature{
+		Name:  author.GetName(),
+		Email: author.GetEmail(),
+		When:  author.GetDate(),
+	}
+}
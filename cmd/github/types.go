@@ -1,5 +1,22 @@
 package github
 
+import "time"
+
+// Statuses of an email lookup.
+const (
+	lookupFound    = "found"
+	lookupNotFound = "not-found"
+	lookupError    = "error"
+)
+
+const (
+	// lookupBatch is the number of emails pushed to a scratch repository.
+	lookupBatch = 500
+	// batchRequests is about the number of core API requests of a batch:
+	// creating, polling and deleting the repository and listing its commits.
+	batchRequests = 10
+)
+
 type options struct {
 	Users           *[]string
 	Emails          *[]string
@@ -15,9 +32,32 @@ type options struct {
 	List            *bool
 	Rate            *bool
 	Search          *bool
+	Provenance      *bool
+	Secrets         *bool
+	SecretRules     *string
+	Files           *bool
+	FilesHistory    *bool
+	Documents       *bool
+	Messages        *bool
+	Anomalies       *bool
+	Cache           *string
+	PullRefs        *bool
 	MaxPullRequests *int
+	ForkNetwork     *bool
+	MaxForks        *int
+	ForkDepth       *int
+	ScratchOwner    *string
+	ScratchReuse    *bool
+	BatchThreads    *int
 	Threads         *int
+	Submodules      *bool
+	SubmoduleDepth  *int
 	Token           *string
 	BaseURL         *string
 	UploadURL       *string
 }
+
+type cleanupOptions struct {
+	DryRun *bool
+	MinAge *time.Duration
+}
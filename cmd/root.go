@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -8,6 +9,7 @@ import (
 	"os/signal"
 
 	"gitosint/cmd/common"
+	"gitosint/cmd/dumpgit"
 	"gitosint/cmd/git"
 	"gitosint/cmd/github"
 
@@ -36,14 +38,6 @@ func NewCommand() *cobra.Command {
 			// defer f.Close()
 
 			// log.SetOutput(f)
-
-			stop := make(chan os.Signal, 1)
-			signal.Notify(stop, os.Interrupt)
-			go func() {
-				<-stop
-				//todo: add cleanup
-				os.Exit(0)
-			}()
 		},
 		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
 		SilenceErrors:     true,
@@ -56,6 +50,7 @@ func NewCommand() *cobra.Command {
 
 	rootCmd.AddCommand(github.NewCommand())
 	rootCmd.AddCommand(git.NewCommand())
+	rootCmd.AddCommand(dumpgit.NewCommand())
 
 	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
 		cmd.Printf("Error: %s\n", err)
@@ -66,8 +61,18 @@ func NewCommand() *cobra.Command {
 }
 
 func Execute() {
+	// an interrupt cancels the context so that commands stop, write the
+	// records they have and clean up; a second one exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	rootCmd := NewCommand()
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		if err != ErrCmd {
 			fmt.Fprintln(os.Stderr, err)
 		}
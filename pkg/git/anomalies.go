@@ -0,0 +1,233 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Anomaly types.
+const (
+	AnomalyCommitterDiffers      = "committer-differs"
+	AnomalyCommitterBeforeAuthor = "committer-before-author"
+	AnomalyFutureDate            = "future-date"
+	AnomalyEarlyDate             = "early-date"
+	AnomalyNameMismatch          = "name-mismatch"
+	AnomalyUnsigned              = "unsigned-commit"
+)
+
+const (
+	// clockSkew is the tolerance of dates in the future.
+	clockSkew = time.Hour
+	// minSigned and minSignedRatio define identities that usually sign:
+	// at least minSigned signed commits making minSignedRatio of theirs.
+	minSigned      = 3
+	minSignedRatio = 0.8
+)
+
+// gitEpoch is the date of the first commit of git itself, older dates are
+// forged or come from imported history.
+var gitEpoch = time.Date(2005, time.April, 7, 0, 0, 0, 0, time.UTC)
+
+// webCommitters commit changes made by others through web interfaces.
+var webCommitters = map[string]struct{}{
+	"noreply@github.com": {},
+	"noreply@gitlab.com": {},
+}
+
+// Anomaly is suspicious history that may have been spoofed or rewritten.
+// Commit is empty for anomalies of an identity.
+type Anomaly struct {
+	Type   string `json:"type"`
+	Commit string `json:"commit,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// DetectAnomalies checks the commits found in the metadata for authors and
+// committers that are different people, committer dates earlier than author
+// dates, dates in the future or before git existed, and unsigned commits of
+// committers that usually sign. It also reports emails used with unrelated
+// names. Identities mapped to the same canonical identity by a mailmap are
+// the same person.
+func DetectAnomalies(repo *git.Repository, metadata CommitMetadata) []*Anomaly {
+	commits := metadata.localCommits(repo)
+
+	var anomalies []*Anomaly
+	now := time.Now()
+	signed := make(map[Metadata]int)
+	committed := make(map[Metadata]int)
+	for _, c := range commits {
+		hash := c.Hash.String()
+		author := Metadata{Email: c.Author.Email, Name: c.Author.Name}
+		committer := Metadata{Email: c.Committer.Email, Name: c.Committer.Name}
+		committed[committer]++
+		if c.PGPSignature != "" {
+			signed[committer]++
+		}
+
+		if !samePerson(metadata, author, committer) {
+			anomalies = append(anomalies, &Anomaly{
+				Type:   AnomalyCommitterDiffers,
+				Commit: hash,
+				Email:  committer.Email,
+				Detail: fmt.Sprintf("authored by %s <%s>, committed by %s <%s>",
+					author.Name, author.Email, committer.Name, committer.Email),
+			})
+		}
+		if c.Committer.When.Before(c.Author.When) {
+			anomalies = append(anomalies, &Anomaly{
+				Type:   AnomalyCommitterBeforeAuthor,
+				Commit: hash,
+				Email:  committer.Email,
+				Detail: fmt.Sprintf("committed %s, authored %s",
+					c.Committer.When.Format(time.RFC3339), c.Author.When.Format(time.RFC3339)),
+			})
+		}
+		for _, sig := range []object.Signature{c.Author, c.Committer} {
+			var anomaly string
+			if sig.When.After(now.Add(clockSkew)) {
+				anomaly = AnomalyFutureDate
+			} else if sig.When.Before(gitEpoch) {
+				anomaly = AnomalyEarlyDate
+			} else {
+				continue
+			}
+			anomalies = append(anomalies, &Anomaly{
+				Type:   anomaly,
+				Commit: hash,
+				Email:  sig.Email,
+				Detail: fmt.Sprintf("%s <%s> dated %s", sig.Name, sig.Email, sig.When.Format(time.RFC3339)),
+			})
+			// the same date for the author and the committer
+			if c.Author.When.Equal(c.Committer.When) {
+				break
+			}
+		}
+	}
+
+	for _, c := range commits {
+		committer := Metadata{Email: c.Committer.Email, Name: c.Committer.Name}
+		n, total := signed[committer], committed[committer]
+		if c.PGPSignature != "" || n < minSigned || float64(n) < minSignedRatio*float64(total) {
+			continue
+		}
+		anomalies = append(anomalies, &Anomaly{
+			Type:   AnomalyUnsigned,
+			Commit: c.Hash.String(),
+			Email:  committer.Email,
+			Detail: fmt.Sprintf("%s <%s> signed %d of %d commits", committer.Name, committer.Email, n, total),
+		})
+	}
+
+	return append(anomalies, nameMismatches(metadata)...)
+}
+
+// nameMismatches reports emails used with names that share no word, such
+// as "John Smith" and "Alice Jones". Initials and abbreviated names
+// ("jsmith", "J. Smith", "Johnny") are considered related.
+func nameMismatches(metadata CommitMetadata) []*Anomaly {
+	names := make(map[string][]string)
+	for k := range metadata {
+		email := strings.ToLower(k.Email)
+		if isGenericEmail(email) {
+			continue
+		}
+		if normalized, _ := normalizeName(k.Name); normalized != "" {
+			names[email] = appendUnique(names[email], k.Name)
+		}
+	}
+
+	var anomalies []*Anomaly
+	for _, email := range sortedEmails(names) {
+		list := names[email]
+		sort.Strings(list)
+		mismatch := false
+		for i := 0; i < len(list) && !mismatch; i++ {
+			for j := i + 1; j < len(list); j++ {
+				if !relatedNames(list[i], list[j]) {
+					mismatch = true
+					break
+				}
+			}
+		}
+		if mismatch {
+			anomalies = append(anomalies, &Anomaly{
+				Type:   AnomalyNameMismatch,
+				Email:  email,
+				Detail: fmt.Sprintf("used with names %s", strings.Join(list, ", ")),
+			})
+		}
+	}
+	return anomalies
+}
+
+// relatedNames reports whether two names share a word, a word is a prefix
+// of another, or the initials of one name appear in the other.
+func relatedNames(a, b string) bool {
+	na, _ := normalizeName(a)
+	nb, _ := normalizeName(b)
+	wa, wb := strings.Fields(na), strings.Fields(nb)
+	for _, x := range wa {
+		for _, y := range wb {
+			short, long := x, y
+			if len(short) > len(long) {
+				short, long = long, short
+			}
+			// "jsmith" contains "smith", "johnny" starts with "john"
+			if len(short) >= 3 && strings.Contains(long, short) || short == long {
+				return true
+			}
+		}
+	}
+	return hasInitials(wa, wb) || hasInitials(wb, wa)
+}
+
+// hasInitials reports whether a single-word name is the initials of the
+// words of the other name.
+func hasInitials(single, words []string) bool {
+	if len(single) != 1 || len(words) < 2 {
+		return false
+	}
+	var initials []byte
+	for _, w := range words {
+		initials = append(initials, w[0])
+	}
+	// words are sorted, so compare letter sets
+	s := []byte(single[0])
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return string(s) == string(initials)
+}
+
+func sortedEmails(m map[string][]string) []string {
+	emails := make([]string, 0, len(m))
+	for email := range m {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	return emails
+}
+
+// samePerson reports whether two identities likely belong to one person:
+// same email, same name, the same canonical identity, or a committer
+// committing on behalf of others through a web interface.
+func samePerson(metadata CommitMetadata, a, b Metadata) bool {
+	if strings.EqualFold(a.Email, b.Email) {
+		return true
+	}
+	if _, ok := webCommitters[strings.ToLower(b.Email)]; ok {
+		return true
+	}
+	if na, _ := normalizeName(a.Name); na != "" {
+		if nb, _ := normalizeName(b.Name); na == nb {
+			return true
+		}
+	}
+	pa, pb := metadata[a], metadata[b]
+	return pa != nil && pb != nil && pa.canonical != nil && pb.canonical != nil &&
+		*pa.canonical == *pb.canonical
+}
@@ -0,0 +1,206 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// cacheDir is the directory of the persistent clone cache, clones are
+// temporary if it is empty.
+var cacheDir string
+
+// CacheState is what was already processed from a cached repository: the
+// commit of every reference and the known identities ("Name <email>").
+type CacheState struct {
+	URL        string              `json:"url"`
+	Refs       map[string]string   `json:"refs"`
+	Identities map[string]struct{} `json:"identities"`
+}
+
+// SetCacheDir sets the directory where remote repositories are cloned once
+// and fetched on later runs instead of being cloned into temporary
+// directories.
+func SetCacheDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	cacheDir = abs
+	return nil
+}
+
+// IsCached reports whether remote repositories are kept in the cache.
+func IsCached() bool {
+	return cacheDir != ""
+}
+
+// cachePath returns the path of the cached repository of the URL. Equivalent
+// URLs share the same cache entry.
+func cachePath(url string) string {
+	sum := sha256.Sum256([]byte(normalizeURL(url)))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:16]))
+}
+
+// cloneCached opens the cached repository of the URL and fetches its new
+// objects, or clones it if it is not cached or the cache entry is broken.
+// Failed fetches, such as network or authentication errors, keep the entry.
+func cloneCached(ctx context.Context, cloneURL string) (*git.Repository, string, error) {
+	dir := cachePath(cloneURL)
+	if repo, err := git.PlainOpen(dir); err == nil && cacheIntact(repo) {
+		err = repo.FetchContext(ctx, &git.FetchOptions{
+			RemoteName:      remoteName,
+			Tags:            git.AllTags,
+			Force:           true,
+			InsecureSkipTLS: true,
+			Auth:            auth,
+		})
+		if err == nil || err == git.NoErrAlreadyUpToDate {
+			return repo, dir, nil
+		}
+		return nil, "", err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, "", err
+	}
+	repo, err := git.PlainCloneContext(ctx, dir, true, &git.CloneOptions{
+		URL:             cloneURL,
+		InsecureSkipTLS: true,
+		Auth:            auth,
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", err
+	}
+	return repo, dir, nil
+}
+
+// cacheIntact reports whether every reference of the cached repository
+// points to an object it has.
+func cacheIntact(repo *git.Repository) bool {
+	refs, err := repo.References()
+	if err != nil {
+		return false
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		_, err := repo.Storer.EncodedObject(plumbing.AnyObject, ref.Hash())
+		return err
+	})
+	return err == nil
+}
+
+// LoadCacheState returns the state of the cached repository of the URL. The
+// state is empty if the repository was never processed.
+func LoadCacheState(url string) (*CacheState, error) {
+	state := &CacheState{
+		URL:        url,
+		Refs:       make(map[string]string),
+		Identities: make(map[string]struct{}),
+	}
+	data, err := ioutil.ReadFile(cachePath(url) + ".json")
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid cache state of '%s': (%s)", url, err.Error())
+	}
+	return state, nil
+}
+
+// Save writes the state next to the cached repository.
+func (s *CacheState) Save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	path := cachePath(s.URL) + ".json"
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// CollectNewMetadata collects the metadata of the commits added since the
+// state was saved, skipping the commits reachable from the previous
+// references. The state is updated with the current references.
+func CollectNewMetadata(repo *git.Repository, state *CacheState) (CommitMetadata, error) {
+	known, err := knownCommits(repo, state)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, refs, err := collectMetadata(repo, known)
+	if err != nil {
+		return nil, err
+	}
+	state.Refs = refs
+	return metadata, nil
+}
+
+// knownCommits returns the previous references of the state and the commits
+// reachable from them, so that new references created on old commits do not
+// walk their history again.
+func knownCommits(repo *git.Repository, state *CacheState) (map[plumbing.Hash]bool, error) {
+	ignore, err := shallowParents(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[plumbing.Hash]bool)
+	for _, tip := range state.Refs {
+		hash := plumbing.NewHash(tip)
+		if tag, err := repo.TagObject(hash); err == nil {
+			known[hash] = true
+			hash = tag.Target
+		}
+		if known[hash] {
+			continue
+		}
+		c, err := repo.CommitObject(hash)
+		if err != nil {
+			// tags of other objects
+			known[hash] = true
+			continue
+		}
+		err = object.NewCommitPreorderIter(c, known, ignore).ForEach(func(c *object.Commit) error {
+			known[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return known, nil
+}
+
+// KeepNew removes from the metadata the identities already known to the
+// state and adds the remaining ones to it.
+func (s *CacheState) KeepNew(metadata CommitMetadata) {
+	for k := range metadata {
+		identity := fmt.Sprintf("%s <%s>", k.Name, k.Email)
+		if _, ok := s.Identities[identity]; ok {
+			delete(metadata, k)
+			continue
+		}
+		s.Identities[identity] = struct{}{}
+	}
+}
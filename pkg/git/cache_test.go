@@ -0,0 +1,39 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestCollectNewMetadata(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := storeCommit(t, repo, "a@example.com")
+	b := storeCommit(t, repo, "b@example.com", a)
+	setRef(t, repo, "refs/heads/master", b)
+
+	state := &CacheState{Identities: make(map[string]struct{})}
+	if _, err := CollectNewMetadata(repo, state); err != nil {
+		t.Fatalf("CollectNewMetadata: %v", err)
+	}
+
+	// a new branch on an old commit and a new commit on master
+	c := storeCommit(t, repo, "c@example.com", b)
+	setRef(t, repo, "refs/heads/master", c)
+	setRef(t, repo, "refs/heads/old", a)
+
+	metadata, err := CollectNewMetadata(repo, state)
+	if err != nil {
+		t.Fatalf("CollectNewMetadata: %v", err)
+	}
+	if len(metadata) != 1 {
+		t.Errorf("expected only the new identity, got %v", ConvertCommitMetadata(metadata))
+	}
+	if _, ok := metadata[Metadata{Email: "c@example.com", Name: "c@example.com"}]; !ok {
+		t.Errorf("identity c@example.com not found")
+	}
+}
@@ -0,0 +1,169 @@
+package git
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Confidence of the links between identities.
+const (
+	mailmapLink    = 1.0
+	emailLink      = 0.95
+	fullNameLink   = 0.8
+	singleNameLink = 0.5
+)
+
+// Names and email local parts shared by unrelated people.
+var genericNames = map[string]struct{}{
+	"admin": {}, "administrator": {}, "root": {}, "user": {}, "unknown": {},
+	"github": {}, "gitlab": {}, "bitbucket": {}, "ubuntu": {}, "debian": {},
+	"git": {}, "noreply": {}, "no-reply": {}, "nobody": {}, "bot": {},
+	"jenkins": {}, "build": {}, "ci": {}, "dependabot": {}, "test": {},
+	"localhost": {}, "none": {},
+}
+
+type clusterLink struct {
+	a, b       Metadata
+	confidence float64
+}
+
+// ClusterIdentities groups identities that likely belong to the same person:
+// names sharing an email, emails sharing a normalized name and identities
+// linked by the mailmap. The confidence of a cluster is the confidence of
+// its weakest link. Only clusters of more than one identity are returned.
+func ClusterIdentities(metadata CommitMetadata) []*Cluster {
+	var links []clusterLink
+	byEmail := make(map[string]Metadata)
+	byName := make(map[string]Metadata)
+	nodes := make(map[Metadata]struct{})
+
+	for k, p := range metadata {
+		nodes[k] = struct{}{}
+		if p.canonical != nil {
+			nodes[*p.canonical] = struct{}{}
+			links = append(links, clusterLink{k, *p.canonical, mailmapLink})
+		}
+	}
+
+	for k := range nodes {
+		if email := strings.ToLower(k.Email); email != "" && !isGenericEmail(email) {
+			if other, ok := byEmail[email]; ok {
+				links = append(links, clusterLink{k, other, emailLink})
+			} else {
+				byEmail[email] = k
+			}
+		}
+
+		if name, tokens := normalizeName(k.Name); name != "" {
+			if other, ok := byName[name]; ok {
+				confidence := singleNameLink
+				if tokens > 1 {
+					confidence = fullNameLink
+				}
+				links = append(links, clusterLink{k, other, confidence})
+			} else {
+				byName[name] = k
+			}
+		}
+	}
+
+	// strongest links first, so that the weakest link of a cluster is the
+	// weakest one needed to connect it
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].confidence > links[j].confidence
+	})
+
+	parent := make(map[Metadata]Metadata)
+	confidence := make(map[Metadata]float64)
+	var find func(Metadata) Metadata
+	find = func(k Metadata) Metadata {
+		p, ok := parent[k]
+		if !ok || p == k {
+			return k
+		}
+		root := find(p)
+		parent[k] = root
+		return root
+	}
+
+	for _, l := range links {
+		a, b := find(l.a), find(l.b)
+		if a == b {
+			continue
+		}
+		c := l.confidence
+		if v, ok := confidence[a]; ok && v < c {
+			c = v
+		}
+		if v, ok := confidence[b]; ok && v < c {
+			c = v
+		}
+		parent[b] = a
+		confidence[a] = c
+	}
+
+	members := make(map[Metadata][]Metadata)
+	for k := range nodes {
+		root := find(k)
+		members[root] = append(members[root], k)
+	}
+
+	var clusters []*Cluster
+	for root, ids := range members {
+		if len(ids) < 2 {
+			continue
+		}
+		cluster := &Cluster{Confidence: confidence[root]}
+		for _, id := range ids {
+			if id.Name != "" {
+				cluster.Names = appendUnique(cluster.Names, id.Name)
+			}
+			if id.Email != "" {
+				cluster.Emails = appendUnique(cluster.Emails, id.Email)
+			}
+		}
+		sort.Strings(cluster.Names)
+		sort.Strings(cluster.Emails)
+		clusters = append(clusters, cluster)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		a, b := clusters[i], clusters[j]
+		if a.Confidence != b.Confidence {
+			return a.Confidence > b.Confidence
+		}
+		return strings.Join(a.Emails, ",") < strings.Join(b.Emails, ",")
+	})
+	return clusters
+}
+
+// normalizeName lowercases the name and sorts its words, so that
+// "Smith, John" and "john.smith" are equal. It returns an empty name for
+// generic names.
+func normalizeName(name string) (string, int) {
+	tokens := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(tokens) == 0 {
+		return "", 0
+	}
+	if _, ok := genericNames[strings.Join(tokens, "-")]; ok {
+		return "", 0
+	}
+	sort.Strings(tokens)
+	return strings.Join(tokens, " "), len(tokens)
+}
+
+func isGenericEmail(email string) bool {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return true
+	}
+	local, domain := email[:i], email[i+1:]
+	if _, ok := genericNames[local]; ok {
+		return true
+	}
+	_, ok := genericNames[domain]
+	return ok
+}
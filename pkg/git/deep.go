@@ -0,0 +1,142 @@
+package git
+
+import (
+	"io/ioutil"
+	"path"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+const (
+	reflogDir = "logs"
+	stashRef  = "refs/stash"
+)
+
+// CollectUnreachable adds to the metadata collected by CollectMetadata the
+// identities of commits that no branch, remote or tag reaches: reflog
+// entries and the identities that made them, stashes and dangling commits
+// left in the object store, for example by rebases. Such identities are
+// marked as unreachable unless they are also seen in reachable commits.
+func CollectUnreachable(repo *git.Repository, metadata CommitMetadata) error {
+	ignore, err := shallowParents(repo)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	for _, p := range metadata {
+		for hash := range p.commits {
+			if _, ok := p.unreachable[hash]; !ok {
+				seen[plumbing.NewHash(hash)] = true
+			}
+		}
+	}
+
+	roots := make(map[string][]plumbing.Hash)
+	if ref, err := repo.Reference(stashRef, false); err == nil && ref.Type() == plumbing.HashReference {
+		roots[stashRef] = append(roots[stashRef], ref.Hash())
+	}
+
+	reflogs, err := readReflogs(repo)
+	if err != nil {
+		return err
+	}
+	for name, entries := range reflogs {
+		for _, entry := range entries {
+			p := metadata.Add(entry.Signature, RoleReflog, "")
+			p.AddRef(name)
+			roots[name] = append(roots[name], entry.Old, entry.New)
+		}
+	}
+
+	for name, hashes := range roots {
+		for _, hash := range hashes {
+			if hash.IsZero() || seen[hash] {
+				continue
+			}
+			commit, err := repo.CommitObject(hash)
+			if err != nil {
+				// reflog entries may outlive the commits they point to
+				continue
+			}
+			iter := object.NewCommitPreorderIter(commit, seen, ignore)
+			err = iter.ForEach(func(c *object.Commit) error {
+				seen[c.Hash] = true
+				addCommit(metadata, c, name, false)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// dangling commits, e.g. left behind by rebases or dropped stashes
+	commits, err := repo.CommitObjects()
+	if err != nil {
+		return err
+	}
+	return commits.ForEach(func(c *object.Commit) error {
+		if !seen[c.Hash] {
+			addCommit(metadata, c, "", false)
+		}
+		return nil
+	})
+}
+
+// readReflogs returns the entries of every reflog of a repository stored on
+// disk, keyed by the reflog path ("logs/HEAD", "logs/refs/heads/master").
+func readReflogs(repo *git.Repository) (map[string][]reflogEntry, error) {
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return nil, nil
+	}
+
+	reflogs := make(map[string][]reflogEntry)
+	err := walkFiles(storage.Filesystem(), reflogDir, func(name string) error {
+		f, err := storage.Filesystem().Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		contents, err := ioutil.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		if entries := parseReflog(string(contents)); len(entries) != 0 {
+			reflogs[name] = entries
+		}
+		return nil
+	})
+	return reflogs, err
+}
+
+// walkFiles calls fn for every file under dir. A missing dir is not an
+// error.
+func walkFiles(fs billy.Filesystem, dir string, fn func(name string) error) error {
+	infos, err := fs.ReadDir(dir)
+	if err != nil {
+		if _, statErr := fs.Stat(dir); statErr != nil {
+			return nil
+		}
+		return err
+	}
+
+	for _, info := range infos {
+		name := path.Join(dir, info.Name())
+		if info.IsDir() {
+			err = walkFiles(fs, name, fn)
+		} else {
+			err = fn(name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
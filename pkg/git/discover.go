@@ -0,0 +1,142 @@
+package git
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// DiscoverRepos walks the directory tree under root and opens every
+// repository found: working trees with a .git directory, linked worktrees
+// and submodules with a .git file, submodule git directories and bare
+// repositories. Repositories sharing an object store are opened once.
+// Origins of the results are relative to root.
+func DiscoverRepos(root string) []OpenResult {
+	var repos []OpenResult
+	seen := make(map[string]struct{})
+
+	add := func(path, gitDir string, open func() (*git.Repository, error)) {
+		key := commonDir(gitDir)
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+
+		repo, err := open()
+		repos = append(repos, OpenResult{
+			Origin: relativePath(root, path),
+			Root:   root,
+			Repo:   repo,
+			Dir:    path,
+			Error:  err,
+		})
+	}
+
+	var walk fs.WalkDirFunc
+	walk = func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// unreadable directories are reported and skipped
+			if path != root && os.IsNotExist(err) {
+				return nil
+			}
+			repos = append(repos, OpenResult{Origin: relativePath(root, path), Root: root, Dir: path, Error: err})
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.Name() == git.GitDirName {
+			parent := filepath.Dir(path)
+			if d.IsDir() {
+				add(parent, path, func() (*git.Repository, error) {
+					return git.PlainOpen(parent)
+				})
+				// submodule git directories live inside the parent's one
+				filepath.WalkDir(filepath.Join(path, "modules"), walk)
+				return fs.SkipDir
+			}
+
+			gitDir, err := readGitFile(path)
+			if err != nil {
+				repos = append(repos, OpenResult{Origin: relativePath(root, parent), Root: root, Dir: parent, Error: err})
+				return nil
+			}
+			add(parent, gitDir, func() (*git.Repository, error) {
+				return git.PlainOpenWithOptions(parent, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+			})
+			return nil
+		}
+
+		if d.IsDir() && isGitDir(path) {
+			add(path, path, func() (*git.Repository, error) {
+				return git.PlainOpen(path)
+			})
+			return fs.SkipDir
+		}
+		return nil
+	}
+
+	filepath.WalkDir(root, walk)
+	return repos
+}
+
+// isGitDir reports whether the directory looks like a git directory of a
+// bare repository or a submodule.
+func isGitDir(path string) bool {
+	for name, dir := range map[string]bool{"HEAD": false, "objects": true, "refs": true} {
+		fi, err := os.Stat(filepath.Join(path, name))
+		if err != nil || fi.IsDir() != dir {
+			return false
+		}
+	}
+	return true
+}
+
+// readGitFile returns the git directory a .git file points to.
+func readGitFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(line, "gitdir: ") {
+		return "", &os.PathError{Op: "read", Path: path, Err: os.ErrInvalid}
+	}
+	gitDir := strings.TrimPrefix(line, "gitdir: ")
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return gitDir, nil
+}
+
+// commonDir returns the resolved path of the git directory holding the
+// object store, which differs from gitDir for linked worktrees.
+func commonDir(gitDir string) string {
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		dir := strings.TrimSpace(string(b))
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(gitDir, dir)
+		}
+		gitDir = dir
+	}
+
+	if resolved, err := filepath.EvalSymlinks(gitDir); err == nil {
+		return resolved
+	}
+	if abs, err := filepath.Abs(gitDir); err == nil {
+		return abs
+	}
+	return gitDir
+}
+
+func relativePath(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,348 @@
+package git
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// maxDocumentSize is the size above which blobs are not read for metadata.
+const maxDocumentSize = 32 << 20
+
+var errBlobTooLarge = errors.New("blob too large")
+
+// Document types.
+const (
+	DocumentPDF   = "pdf"
+	DocumentOOXML = "ooxml"
+	DocumentEXIF  = "exif"
+)
+
+// Document is the metadata embedded in a document or image of the
+// repository. Commit is the first commit that added the blob.
+type Document struct {
+	Path   string            `json:"path"`
+	Commit string            `json:"commit"`
+	Type   string            `json:"type"`
+	Fields map[string]string `json:"fields"`
+}
+
+var (
+	pdfInfoRe = regexp.MustCompile(`/(Author|Creator|Producer|Title|Subject|Company|SourceModified)\s*(\((?:\\.|[^\\)])*\)|<[0-9A-Fa-f\s]*>)`)
+	pdfXMPRe  = regexp.MustCompile(`(?s)<(dc:creator|xmp:CreatorTool|pdf:Producer|xmpMM:DocumentID)>(.*?)</(?:dc:creator|xmp:CreatorTool|pdf:Producer|xmpMM:DocumentID)>`)
+	xmlTagRe  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// documentParsers parse the metadata of a document type, keyed by file
+// extension.
+var documentParsers = map[string]struct {
+	Type  string
+	Parse func([]byte) map[string]string
+}{
+	".pdf":  {DocumentPDF, parsePDF},
+	".docx": {DocumentOOXML, parseOOXML},
+	".docm": {DocumentOOXML, parseOOXML},
+	".dotx": {DocumentOOXML, parseOOXML},
+	".xlsx": {DocumentOOXML, parseOOXML},
+	".xlsm": {DocumentOOXML, parseOOXML},
+	".pptx": {DocumentOOXML, parseOOXML},
+	".pptm": {DocumentOOXML, parseOOXML},
+	".jpg":  {DocumentEXIF, parseJPEG},
+	".jpeg": {DocumentEXIF, parseJPEG},
+	".tif":  {DocumentEXIF, parseEXIF},
+	".tiff": {DocumentEXIF, parseEXIF},
+}
+
+// CollectDocuments returns the metadata of the PDF, Office Open XML
+// documents and JPEG or TIFF images added by the commits found in the
+// metadata. Each blob is reported once, with the oldest commit adding it.
+// Merge commits are skipped, as their changes are introduced by their
+// parents.
+func CollectDocuments(repo *git.Repository, metadata CommitMetadata) ([]*Document, error) {
+	var commits []*object.Commit
+	for _, c := range metadata.localCommits(repo) {
+		if c.NumParents() <= 1 {
+			commits = append(commits, c)
+		}
+	}
+	sort.Slice(commits, func(i, j int) bool {
+		return commits[i].Committer.When.Before(commits[j].Committer.When)
+	})
+
+	var documents []*Document
+	var failed []string
+	var changesErr error
+	seen := make(map[plumbing.Hash]struct{})
+	for _, c := range commits {
+		changes, err := commitChanges(c)
+		if err != nil {
+			// keep the documents of the other commits
+			failed = append(failed, c.Hash.String())
+			if changesErr == nil {
+				changesErr = err
+			}
+			continue
+		}
+		for _, change := range changes {
+			action, err := change.Action()
+			if err != nil || action == merkletrie.Delete {
+				continue
+			}
+			entry := change.To.TreeEntry
+			parser, ok := documentParsers[strings.ToLower(path.Ext(entry.Name))]
+			if !ok {
+				continue
+			}
+			if _, ok := seen[entry.Hash]; ok {
+				continue
+			}
+			seen[entry.Hash] = struct{}{}
+
+			data, err := readBlob(repo, entry.Hash)
+			if err != nil {
+				continue
+			}
+			if fields := parser.Parse(data); len(fields) != 0 {
+				documents = append(documents, &Document{
+					Path:   change.To.Name,
+					Commit: c.Hash.String(),
+					Type:   parser.Type,
+					Fields: fields,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(documents, func(i, j int) bool {
+		return documents[i].Path < documents[j].Path
+	})
+	if len(failed) != 0 {
+		return documents, fmt.Errorf("failed to read changes of %d commits, first '%s': (%s)",
+			len(failed), failed[0], changesErr.Error())
+	}
+	return documents, nil
+}
+
+// commitChanges returns the changes of the commit to its first parent, or
+// to the empty tree for root commits.
+func commitChanges(c *object.Commit) (object.Changes, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	parentTree := &object.Tree{}
+	if c.NumParents() != 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	return object.DiffTree(parentTree, tree)
+}
+
+func readBlob(repo *git.Repository, hash plumbing.Hash) ([]byte, error) {
+	blob, err := repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if blob.Size > maxDocumentSize {
+		return nil, errBlobTooLarge
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// parsePDF returns the fields of the document information dictionary and of
+// the XMP metadata stream. Dictionaries in compressed object streams are not
+// read.
+func parsePDF(data []byte) map[string]string {
+	fields := make(map[string]string)
+	for _, m := range pdfInfoRe.FindAllSubmatch(data, -1) {
+		if value := pdfString(m[2]); value != "" {
+			fields[fieldName(string(m[1]))] = value
+		}
+	}
+	for _, m := range pdfXMPRe.FindAllSubmatch(data, -1) {
+		value := strings.TrimSpace(xmlTagRe.ReplaceAllString(string(m[2]), " "))
+		value = strings.Join(strings.Fields(value), " ")
+		name := fieldName(string(m[1][strings.IndexByte(string(m[1]), ':')+1:]))
+		if _, ok := fields[name]; !ok && value != "" {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// pdfString decodes a literal "(...)" or hexadecimal "<...>" PDF string,
+// either PDFDocEncoded (read as Latin-1) or UTF-16BE with a byte order mark.
+func pdfString(s []byte) string {
+	var raw []byte
+	if s[0] == '<' {
+		hex := strings.Join(strings.Fields(string(s[1:len(s)-1])), "")
+		if len(hex)%2 == 1 {
+			hex += "0"
+		}
+		for i := 0; i < len(hex); i += 2 {
+			var b byte
+			for _, c := range hex[i : i+2] {
+				b <<= 4
+				switch {
+				case c >= '0' && c <= '9':
+					b |= byte(c - '0')
+				case c >= 'a' && c <= 'f':
+					b |= byte(c - 'a' + 10)
+				case c >= 'A' && c <= 'F':
+					b |= byte(c - 'A' + 10)
+				}
+			}
+			raw = append(raw, b)
+		}
+	} else {
+		body := s[1 : len(s)-1]
+		for i := 0; i < len(body); i++ {
+			if body[i] != '\\' || i+1 == len(body) {
+				raw = append(raw, body[i])
+				continue
+			}
+			i++
+			switch c := body[i]; c {
+			case 'n':
+				raw = append(raw, '\n')
+			case 'r':
+				raw = append(raw, '\r')
+			case 't':
+				raw = append(raw, '\t')
+			case 'b', 'f':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				v := 0
+				for j := 0; j < 3 && i < len(body) && body[i] >= '0' && body[i] <= '7'; j++ {
+					v = v*8 + int(body[i]-'0')
+					i++
+				}
+				i--
+				raw = append(raw, byte(v))
+			default:
+				raw = append(raw, c)
+			}
+		}
+	}
+	return strings.TrimSpace(decodeText(raw))
+}
+
+// decodeText decodes UTF-16 with a byte order mark, or else Latin-1.
+func decodeText(raw []byte) string {
+	if len(raw) >= 2 && (raw[0] == 0xfe && raw[1] == 0xff || raw[0] == 0xff && raw[1] == 0xfe) {
+		var order binary.ByteOrder = binary.BigEndian
+		if raw[0] == 0xff {
+			order = binary.LittleEndian
+		}
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, order.Uint16(raw[i:]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// parseOOXML returns the core and extended properties of an Office Open XML
+// document.
+func parseOOXML(data []byte) map[string]string {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	for _, f := range r.File {
+		if f.Name != "docProps/core.xml" && f.Name != "docProps/app.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		decoder := xml.NewDecoder(rc)
+		var name string
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				break
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				name = t.Name.Local
+			case xml.CharData:
+				value := strings.TrimSpace(string(t))
+				if ooxmlFields[name] && value != "" {
+					fields[fieldName(name)] = value
+				}
+			case xml.EndElement:
+				name = ""
+			}
+		}
+		rc.Close()
+	}
+	return fields
+}
+
+// ooxmlFields are the properties of core.xml and app.xml reported.
+var ooxmlFields = map[string]bool{
+	"creator":        true,
+	"lastModifiedBy": true,
+	"title":          true,
+	"subject":        true,
+	"keywords":       true,
+	"description":    true,
+	"created":        true,
+	"modified":       true,
+	"lastPrinted":    true,
+	"Company":        true,
+	"Manager":        true,
+	"Application":    true,
+	"AppVersion":     true,
+	"Template":       true,
+	"HyperlinkBase":  true,
+}
+
+// fieldName converts a metadata property name such as "lastModifiedBy" or
+// "CreatorTool" to snake case.
+func fieldName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 && !(name[i-1] >= 'A' && name[i-1] <= 'Z') {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
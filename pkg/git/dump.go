@@ -0,0 +1,387 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/objfile"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// dumpRefPrefix is the prefix of the branches created for commits recovered
+// from a dump that no reference points to, such as reflog entries.
+const dumpRefPrefix = "refs/heads/dump/"
+
+// Branch names probed when the refs of an exposed .git directory cannot be
+// listed.
+var dumpBranches = []string{"master", "main", "develop", "dev", "trunk", "staging", "production"}
+
+// DumpResult is a repository reconstructed from an exposed .git directory.
+type DumpResult struct {
+	Repo   *git.Repository
+	Config *LeakedConfig
+	// Missing counts the objects that were referenced but not found.
+	Missing int
+}
+
+// LeakedConfig holds the interesting parts of a leaked .git/config.
+type LeakedConfig struct {
+	Remotes   map[string][]string `json:"remotes,omitempty"`
+	UserName  string              `json:"user_name,omitempty"`
+	UserEmail string              `json:"user_email,omitempty"`
+}
+
+type dumper struct {
+	ctx     context.Context
+	client  *http.Client
+	baseURL string
+	storer  *memory.Storage
+	threads int
+}
+
+// NewHTTPClient returns the HTTP client used to download exposed .git
+// directories. Like clones, it does not verify TLS certificates.
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+}
+
+// DumpRepo reconstructs a repository from a .git directory served over plain
+// HTTP (without the smart protocol) at baseURL. Objects are collected from
+// the packs listed in objects/info/packs and from loose objects reachable
+// from HEAD, packed-refs, common branch names and logs/HEAD.
+func DumpRepo(ctx context.Context, client *http.Client, baseURL string, threads int) (*DumpResult, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if !strings.HasSuffix(baseURL, ".git") {
+		baseURL += "/.git"
+	}
+	if threads < 1 {
+		threads = 1
+	}
+
+	repo, storer, err := newMemoryRepo()
+	if err != nil {
+		return nil, err
+	}
+	d := &dumper{ctx: ctx, client: client, baseURL: baseURL, storer: storer, threads: threads}
+
+	head, err := d.fetch("HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("no exposed .git directory: %s", err)
+	}
+
+	result := &DumpResult{Repo: repo}
+	if data, err := d.fetch("config"); err == nil {
+		result.Config = parseLeakedConfig(data)
+	}
+
+	refs := d.fetchRefs(strings.TrimSpace(string(head)))
+	var roots []plumbing.Hash
+	for _, hash := range refs {
+		roots = append(roots, hash)
+	}
+	for _, name := range []string{"logs/HEAD", "ORIG_HEAD", "FETCH_HEAD"} {
+		data, err := d.fetch(name)
+		if err != nil {
+			continue
+		}
+		if name == "logs/HEAD" {
+			for _, entry := range parseReflog(string(data)) {
+				roots = append(roots, entry.Old, entry.New)
+			}
+		} else if fields := strings.Fields(string(data)); len(fields) > 0 && plumbing.IsHash(fields[0]) {
+			roots = append(roots, plumbing.NewHash(fields[0]))
+		}
+	}
+
+	d.fetchPacks()
+	result.Missing = d.fetchObjects(roots)
+
+	for name, hash := range refs {
+		if _, err := storer.EncodedObject(plumbing.AnyObject, hash); err != nil {
+			continue
+		}
+		if err := storer.SetReference(plumbing.NewHashReference(name, hash)); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(string(head), "ref: ") {
+		target := plumbing.ReferenceName(strings.TrimSpace(strings.TrimPrefix(string(head), "ref: ")))
+		storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, target))
+	}
+
+	if err := createTipRefs(repo, dumpRefPrefix); err != nil {
+		return nil, err
+	}
+	if err := markShallow(repo, storer); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// markShallow marks the recovered commits with missing parents as shallow,
+// so that their history is walked up to the missing commits.
+func markShallow(repo *git.Repository, storer *memory.Storage) error {
+	commits, err := repo.CommitObjects()
+	if err != nil {
+		return err
+	}
+
+	var shallow []plumbing.Hash
+	err = commits.ForEach(func(c *object.Commit) error {
+		for _, parent := range c.ParentHashes {
+			if storer.HasEncodedObject(parent) != nil {
+				shallow = append(shallow, c.Hash)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return storer.SetShallow(shallow)
+}
+
+func (d *dumper) fetch(path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, d.baseURL+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// fetchRefs returns the references found in packed-refs, the loose refs of
+// HEAD and of common branch names, and the branches checked out according
+// to logs/HEAD.
+func (d *dumper) fetchRefs(head string) map[plumbing.ReferenceName]plumbing.Hash {
+	refs := make(map[plumbing.ReferenceName]plumbing.Hash)
+
+	if data, err := d.fetch("packed-refs"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			parts := strings.Fields(line)
+			if len(parts) == 2 && plumbing.IsHash(parts[0]) {
+				refs[plumbing.ReferenceName(parts[1])] = plumbing.NewHash(parts[0])
+			}
+		}
+	}
+
+	candidates := []string{"refs/stash", "refs/remotes/origin/HEAD"}
+	if strings.HasPrefix(head, "ref: ") {
+		candidates = append(candidates, strings.TrimPrefix(head, "ref: "))
+	} else if plumbing.IsHash(head) {
+		refs[plumbing.HEAD] = plumbing.NewHash(head)
+	}
+	for _, branch := range dumpBranches {
+		candidates = append(candidates, "refs/heads/"+branch, "refs/remotes/origin/"+branch)
+	}
+	if data, err := d.fetch("logs/HEAD"); err == nil {
+		for _, entry := range parseReflog(string(data)) {
+			// "checkout: moving from <branch> to <branch>"
+			if fields := strings.Fields(entry.Message); len(fields) == 6 && fields[0] == "checkout:" {
+				candidates = append(candidates, "refs/heads/"+fields[3], "refs/heads/"+fields[5])
+			}
+		}
+	}
+
+	for _, name := range candidates {
+		if _, ok := refs[plumbing.ReferenceName(name)]; ok {
+			continue
+		}
+		data, err := d.fetch(name)
+		if err != nil {
+			continue
+		}
+		if hash := strings.TrimSpace(string(data)); plumbing.IsHash(hash) {
+			refs[plumbing.ReferenceName(name)] = plumbing.NewHash(hash)
+		}
+	}
+	return refs
+}
+
+// fetchPacks stores the objects of the packs listed in objects/info/packs.
+// Packs that cannot be downloaded or parsed are skipped.
+func (d *dumper) fetchPacks() {
+	data, err := d.fetch("objects/info/packs")
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "P" {
+			continue
+		}
+		pack, err := d.fetch("objects/pack/" + fields[1])
+		if err != nil {
+			continue
+		}
+		packfile.UpdateObjectStorage(d.storer, bytes.NewReader(pack))
+	}
+}
+
+// fetchObjects walks the object graph from roots breadth-first, downloading
+// missing loose objects concurrently. It returns the number of objects that
+// could not be found.
+func (d *dumper) fetchObjects(roots []plumbing.Hash) int {
+	visited := make(map[plumbing.Hash]struct{})
+	missing := 0
+	frontier := roots
+
+	for len(frontier) > 0 {
+		var level, download []plumbing.Hash
+		for _, hash := range frontier {
+			if _, ok := visited[hash]; ok || hash.IsZero() {
+				continue
+			}
+			visited[hash] = struct{}{}
+			level = append(level, hash)
+			if _, err := d.storer.EncodedObject(plumbing.AnyObject, hash); err != nil {
+				download = append(download, hash)
+			}
+		}
+
+		for _, obj := range d.fetchLooseObjects(download) {
+			if obj != nil {
+				d.storer.SetEncodedObject(obj)
+			}
+		}
+
+		frontier = nil
+		for _, hash := range level {
+			obj, err := d.storer.EncodedObject(plumbing.AnyObject, hash)
+			if err != nil {
+				missing++
+				continue
+			}
+			frontier = append(frontier, dependencies(d.storer, obj)...)
+		}
+	}
+	return missing
+}
+
+func (d *dumper) fetchLooseObjects(hashes []plumbing.Hash) []plumbing.EncodedObject {
+	objects := make([]plumbing.EncodedObject, len(hashes))
+	indexCh := make(chan int, len(hashes))
+	for i := range hashes {
+		indexCh <- i
+	}
+	close(indexCh)
+
+	var wg sync.WaitGroup
+	wg.Add(d.threads)
+	for i := 0; i < d.threads; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexCh {
+				obj, err := d.fetchLooseObject(hashes[i])
+				if err == nil {
+					objects[i] = obj
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return objects
+}
+
+func (d *dumper) fetchLooseObject(hash plumbing.Hash) (plumbing.EncodedObject, error) {
+	h := hash.String()
+	data, err := d.fetch("objects/" + h[:2] + "/" + h[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := objfile.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	t, size, err := r.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(t)
+	obj.SetSize(size)
+	w, err := obj.Writer()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return nil, err
+	}
+	if obj.Hash() != hash {
+		return nil, fmt.Errorf("object %s: hash mismatch", h)
+	}
+	return obj, nil
+}
+
+// dependencies returns the objects the object refers to. Submodule entries
+// of trees refer to commits of other repositories and are skipped.
+func dependencies(storer *memory.Storage, obj plumbing.EncodedObject) []plumbing.Hash {
+	decoded, err := object.DecodeObject(storer, obj)
+	if err != nil {
+		return nil
+	}
+
+	var hashes []plumbing.Hash
+	switch o := decoded.(type) {
+	case *object.Commit:
+		hashes = append(hashes, o.TreeHash)
+		hashes = append(hashes, o.ParentHashes...)
+	case *object.Tree:
+		for _, entry := range o.Entries {
+			if entry.Mode != filemode.Submodule {
+				hashes = append(hashes, entry.Hash)
+			}
+		}
+	case *object.Tag:
+		hashes = append(hashes, o.Target)
+	}
+	return hashes
+}
+
+func parseLeakedConfig(data []byte) *LeakedConfig {
+	cfg := config.NewConfig()
+	if err := cfg.Unmarshal(data); err != nil {
+		return nil
+	}
+
+	leaked := &LeakedConfig{
+		Remotes:   make(map[string][]string),
+		UserName:  cfg.Raw.Section("user").Option("name"),
+		UserEmail: cfg.Raw.Section("user").Option("email"),
+	}
+	for name, remote := range cfg.Remotes {
+		leaked.Remotes[name] = remote.URLs
+	}
+	return leaked
+}
@@ -0,0 +1,146 @@
+package git
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// exposedRepo creates a repository with a commit by each email, oldest
+// first, and returns its directory and commit hashes.
+func exposedRepo(t *testing.T, emails ...string) (string, []plumbing.Hash) {
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hashes []plumbing.Hash
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, email := range emails {
+		if err := ioutil.WriteFile(filepath.Join(dir, "file"), []byte(email), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add("file"); err != nil {
+			t.Fatal(err)
+		}
+		signature := &object.Signature{Name: email, Email: email, When: when.Add(time.Duration(i) * time.Hour)}
+		hash, err := worktree.Commit(email, &git.CommitOptions{Author: signature, Committer: signature})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return dir, hashes
+}
+
+// serveGitDir serves the .git directory of dir without the smart protocol,
+// answering 404 for the given objects.
+func serveGitDir(dir string, hidden ...plumbing.Hash) *httptest.Server {
+	hide := make(map[string]struct{})
+	for _, hash := range hidden {
+		hide["/.git/objects/"+hash.String()[:2]+"/"+hash.String()[2:]] = struct{}{}
+	}
+	files := http.StripPrefix("/.git/", http.FileServer(http.Dir(filepath.Join(dir, ".git"))))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := hide[r.URL.Path]; ok {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+}
+
+func dumpEmails(t *testing.T, server *httptest.Server) (*DumpResult, map[string]struct{}) {
+	result, err := DumpRepo(context.Background(), NewHTTPClient(), server.URL, 2)
+	if err != nil {
+		t.Fatalf("DumpRepo: %v", err)
+	}
+	metadata, err := CollectMetadata(result.Repo)
+	if err != nil {
+		t.Fatalf("CollectMetadata: %v", err)
+	}
+	emails := make(map[string]struct{})
+	for k := range metadata {
+		emails[k.Email] = struct{}{}
+	}
+	return result, emails
+}
+
+func TestDumpRepo(t *testing.T) {
+	dir, _ := exposedRepo(t, "a@example.com", "b@example.com", "c@example.com")
+	server := serveGitDir(dir)
+	defer server.Close()
+
+	result, emails := dumpEmails(t, server)
+	if result.Missing != 0 {
+		t.Errorf("missing = %d, want 0", result.Missing)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, ok := emails[email]; !ok {
+			t.Errorf("identity %s not found", email)
+		}
+	}
+}
+
+func TestDumpRepoMissingObjects(t *testing.T) {
+	dir, hashes := exposedRepo(t, "a@example.com", "b@example.com", "c@example.com")
+	server := serveGitDir(dir, hashes[0])
+	defer server.Close()
+
+	result, emails := dumpEmails(t, server)
+	if result.Missing != 1 {
+		t.Errorf("missing = %d, want 1", result.Missing)
+	}
+	for _, email := range []string{"b@example.com", "c@example.com"} {
+		if _, ok := emails[email]; !ok {
+			t.Errorf("identity %s not found", email)
+		}
+	}
+	if _, ok := emails["a@example.com"]; ok {
+		t.Errorf("identity of the missing commit found")
+	}
+}
+
+func TestDumpRepoMissingMiddleCommit(t *testing.T) {
+	dir, hashes := exposedRepo(t, "a@example.com", "b@example.com", "c@example.com")
+	server := serveGitDir(dir, hashes[1])
+	defer server.Close()
+
+	// the root commit is cut off from the branch and only found if the
+	// server lists it, which a plain directory listing does not
+	result, emails := dumpEmails(t, server)
+	if result.Missing == 0 {
+		t.Errorf("missing = 0, want the hidden commit")
+	}
+	if _, ok := emails["c@example.com"]; !ok {
+		t.Errorf("identity c@example.com not found")
+	}
+}
+
+func TestDumpRepoNotExposed(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := DumpRepo(context.Background(), NewHTTPClient(), server.URL, 2); err == nil {
+		t.Error("DumpRepo succeeded without an exposed .git directory")
+	}
+}
@@ -0,0 +1,198 @@
+package git
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// EXIF tags reported, by IFD.
+var (
+	exifTags = map[uint16]string{
+		0x010e: "description",
+		0x010f: "make",
+		0x0110: "model",
+		0x0131: "software",
+		0x0132: "date",
+		0x013b: "artist",
+		0x8298: "copyright",
+		0x9003: "date_original",
+		0x9c9b: "title",
+		0x9c9c: "comment",
+		0x9c9d: "author",
+		0x9c9f: "subject",
+		0xa430: "owner",
+		0xa431: "serial_number",
+		0xa434: "lens",
+	}
+	exifIFDTag = uint16(0x8769)
+	gpsIFDTag  = uint16(0x8825)
+)
+
+// parseJPEG returns the EXIF fields of the APP1 segment of a JPEG image.
+func parseJPEG(data []byte) map[string]string {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return nil
+		}
+		marker := data[i+1]
+		// start of scan, image data follows
+		if marker == 0xda {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return nil
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseEXIF(segment[6:])
+		}
+		i += 2 + size
+	}
+	return nil
+}
+
+// parseEXIF returns the fields of the IFD0, EXIF and GPS directories of a
+// TIFF structure, as found in TIFF images and JPEG APP1 segments.
+func parseEXIF(data []byte) map[string]string {
+	if len(data) < 8 {
+		return nil
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+	if order.Uint16(data[2:]) != 42 {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	ifd0 := readIFD(data, order, order.Uint32(data[4:]))
+	for _, ifd := range []map[uint16]tiffValue{ifd0, readIFD(data, order, ifd0[exifIFDTag].uint32())} {
+		for tag, value := range ifd {
+			name, ok := exifTags[tag]
+			if !ok {
+				continue
+			}
+			if s := value.text(); s != "" {
+				fields[name] = s
+			}
+		}
+	}
+
+	gps := readIFD(data, order, ifd0[gpsIFDTag].uint32())
+	lat, latOK := gps[2].degrees()
+	lon, lonOK := gps[4].degrees()
+	if latOK && lonOK {
+		if strings.HasPrefix(gps[1].text(), "S") {
+			lat = -lat
+		}
+		if strings.HasPrefix(gps[3].text(), "W") {
+			lon = -lon
+		}
+		fields["gps"] = fmt.Sprintf("%.6f,%.6f", lat, lon)
+	}
+	return fields
+}
+
+// tiffValue is the value of an IFD entry.
+type tiffValue struct {
+	Type  uint16
+	Count uint32
+	Data  []byte
+	order binary.ByteOrder
+}
+
+// readIFD returns the entries of the image file directory at offset. Zero
+// offset means no directory.
+func readIFD(data []byte, order binary.ByteOrder, offset uint32) map[uint16]tiffValue {
+	entries := make(map[uint16]tiffValue)
+	if offset == 0 || int(offset)+2 > len(data) {
+		return entries
+	}
+
+	n := int(order.Uint16(data[offset:]))
+	for i := 0; i < n; i++ {
+		entry := int(offset) + 2 + i*12
+		if entry+12 > len(data) {
+			break
+		}
+		value := tiffValue{
+			Type:  order.Uint16(data[entry+2:]),
+			Count: order.Uint32(data[entry+4:]),
+			order: order,
+		}
+		size := tiffTypeSizes[value.Type] * int(value.Count)
+		if size <= 0 || size > len(data) {
+			continue
+		}
+		start := entry + 8
+		if size > 4 {
+			start = int(order.Uint32(data[entry+8:]))
+		}
+		if start+size > len(data) {
+			continue
+		}
+		value.Data = data[start : start+size]
+		entries[order.Uint16(data[entry:])] = value
+	}
+	return entries
+}
+
+// tiffTypeSizes are the sizes of the TIFF field types, by type.
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+func (v tiffValue) uint32() uint32 {
+	switch {
+	case v.Type == 3 && len(v.Data) >= 2:
+		return uint32(v.order.Uint16(v.Data))
+	case v.Type == 4 && len(v.Data) >= 4:
+		return v.order.Uint32(v.Data)
+	}
+	return 0
+}
+
+// text returns ASCII values, and the UCS-2 byte values of the Windows XP
+// tags.
+func (v tiffValue) text() string {
+	switch v.Type {
+	case 2:
+		return strings.TrimSpace(strings.TrimRight(string(v.Data), "\x00"))
+	case 1:
+		units := make([]uint16, 0, len(v.Data)/2)
+		for i := 0; i+1 < len(v.Data); i += 2 {
+			units = append(units, binary.LittleEndian.Uint16(v.Data[i:]))
+		}
+		return strings.TrimSpace(strings.TrimRight(string(utf16.Decode(units)), "\x00"))
+	}
+	return ""
+}
+
+// degrees converts a GPS coordinate of three rationals (degrees, minutes,
+// seconds) to decimal degrees.
+func (v tiffValue) degrees() (float64, bool) {
+	if v.Type != 5 || v.Count != 3 {
+		return 0, false
+	}
+	var d float64
+	for i, unit := range []float64{1, 60, 3600} {
+		num := v.order.Uint32(v.Data[i*8:])
+		den := v.order.Uint32(v.Data[i*8+4:])
+		if den == 0 {
+			return 0, false
+		}
+		d += float64(num) / float64(den) / unit
+	}
+	return d, true
+}
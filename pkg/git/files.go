@@ -0,0 +1,278 @@
+package git
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// maxFileSize is the size above which files with a parser are not read.
+	maxFileSize = 1 << 20
+	// headerSize is the size of the beginning of a file searched for
+	// copyright headers.
+	headerSize = 4096
+)
+
+var (
+	// "Name <email>", the name is optional
+	nameEmailRe = regexp.MustCompile(`([^<>,;:"'\n]*?)\s*<([^<>\s@]+@[^<>\s@]+\.[^<>\s@]+)>`)
+	copyrightRe = regexp.MustCompile(`(?i)copyright\s*(?:\(c\)|©)?[\s\d,-]*([^<>\n]*?)\s*<([^<>\s@]+@[^<>\s@]+\.[^<>\s@]+)>`)
+	// Cargo.toml authors array
+	cargoAuthorsRe = regexp.MustCompile(`(?s)\bauthors\s*=\s*\[(.*?)\]`)
+	quotedRe       = regexp.MustCompile(`"([^"]*)"`)
+	// setup.py keyword arguments
+	setupRe = regexp.MustCompile(`\b(author|author_email|maintainer|maintainer_email)\s*=\s*['"]([^'"]*)['"]`)
+	// debian/control fields
+	debianFieldRe = regexp.MustCompile(`(?m)^(?:Maintainer|Uploaders|XSBC-Original-Maintainer):\s*(.+(?:\n[ \t].+)*)`)
+)
+
+// fileIdentity is an identity found in the contents of a file.
+type fileIdentity struct {
+	Name  string
+	Email string
+}
+
+// CollectFileIdentities adds to the metadata the identities found in the
+// files of the HEAD tree: AUTHORS, CONTRIBUTORS and MAINTAINERS lists,
+// package.json, pom.xml, Cargo.toml, setup.py and debian/control
+// maintainers, and copyright headers with an email. If history is set, the
+// files of every commit found in the metadata are searched too. Identities
+// are recorded with the file role and the paths they were found in.
+func CollectFileIdentities(repo *git.Repository, metadata CommitMetadata, history bool) error {
+	var commits []*object.Commit
+	head, err := repo.Head()
+	if err == nil {
+		c, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return err
+		}
+		commits = append(commits, c)
+	} else if err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	if history {
+		commits = append(commits, metadata.localCommits(repo)...)
+	}
+
+	// the same blob is read once per parser, whatever the number of paths
+	// and commits having it, and its identities recorded with every path
+	found := make(map[blobParser][]fileIdentity)
+	for _, c := range commits {
+		files, err := c.Files()
+		if err != nil {
+			return err
+		}
+		err = files.ForEach(func(f *object.File) error {
+			kind, extract := fileParser(f.Name)
+			key := blobParser{hash: f.Hash, kind: kind}
+			identities, ok := found[key]
+			if !ok {
+				identities = extractIdentities(f, extract)
+				found[key] = identities
+			}
+
+			for _, id := range identities {
+				p := metadata.Add(object.Signature{Name: id.Name, Email: id.Email}, RoleFile, "")
+				p.AddFile(f.Name)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if mailmap, err := ReadMailmap(repo); err == nil {
+		ApplyMailmap(metadata, mailmap)
+	}
+	return nil
+}
+
+// blobParser identifies the identities extracted from a blob by a parser.
+type blobParser struct {
+	hash plumbing.Hash
+	kind string
+}
+
+// fileParser returns the parser of the file contents, nil if only its
+// copyright header is searched.
+func fileParser(filePath string) (string, func(string) []fileIdentity) {
+	name := strings.ToLower(path.Base(filePath))
+	base := strings.TrimSuffix(name, path.Ext(name))
+	switch {
+	case base == "authors" || base == "contributors" || base == "maintainers":
+		return "authors", parseNameEmails
+	case name == "package.json":
+		return name, parsePackageJSON
+	case name == "pom.xml":
+		return name, parsePom
+	case name == "cargo.toml":
+		return name, parseCargo
+	case name == "setup.py":
+		return name, parseSetup
+	case strings.HasSuffix(strings.ToLower(filePath), "debian/control"):
+		return "debian/control", parseDebianControl
+	}
+	return "", nil
+}
+
+// extractIdentities returns the identities found by the parser and in the
+// copyright header of the file. Files without a parser are read up to the
+// header size only. Unreadable and binary files, and large files with a
+// parser, are skipped.
+func extractIdentities(f *object.File, extract func(string) []fileIdentity) []fileIdentity {
+	if extract != nil && f.Size > maxFileSize {
+		return nil
+	}
+	if binary, err := f.IsBinary(); err != nil || binary {
+		return nil
+	}
+
+	r, err := f.Reader()
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	var data []byte
+	if extract != nil {
+		data, err = ioutil.ReadAll(r)
+	} else {
+		data, err = ioutil.ReadAll(io.LimitReader(r, headerSize))
+	}
+	if err != nil {
+		return nil
+	}
+
+	var identities []fileIdentity
+	if extract != nil {
+		identities = extract(string(data))
+	}
+	if len(data) > headerSize {
+		data = data[:headerSize]
+	}
+	return append(identities, parseCopyright(string(data))...)
+}
+
+// parseNameEmails returns every "Name <email>" of the contents.
+func parseNameEmails(contents string) []fileIdentity {
+	var identities []fileIdentity
+	for _, m := range nameEmailRe.FindAllStringSubmatch(contents, -1) {
+		identities = append(identities, newFileIdentity(m[1], m[2]))
+	}
+	return identities
+}
+
+func parseCopyright(contents string) []fileIdentity {
+	var identities []fileIdentity
+	for _, m := range copyrightRe.FindAllStringSubmatch(contents, -1) {
+		identities = append(identities, newFileIdentity(m[1], m[2]))
+	}
+	return identities
+}
+
+// parsePackageJSON returns the author, contributors and maintainers of an
+// npm package. People are either "Name <email> (url)" strings or objects.
+func parsePackageJSON(contents string) []fileIdentity {
+	var pkg struct {
+		Author       json.RawMessage   `json:"author"`
+		Contributors []json.RawMessage `json:"contributors"`
+		Maintainers  []json.RawMessage `json:"maintainers"`
+	}
+	if err := json.Unmarshal([]byte(contents), &pkg); err != nil {
+		return nil
+	}
+
+	var identities []fileIdentity
+	people := append([]json.RawMessage{pkg.Author}, pkg.Contributors...)
+	for _, raw := range append(people, pkg.Maintainers...) {
+		var s string
+		var person struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		}
+		if err := json.Unmarshal(raw, &s); err == nil {
+			identities = append(identities, parseNameEmails(s)...)
+		} else if err := json.Unmarshal(raw, &person); err == nil && person.Email != "" {
+			identities = append(identities, newFileIdentity(person.Name, person.Email))
+		}
+	}
+	return identities
+}
+
+// parsePom returns the developers and contributors of a Maven project.
+func parsePom(contents string) []fileIdentity {
+	type person struct {
+		Name  string `xml:"name"`
+		Email string `xml:"email"`
+	}
+	var pom struct {
+		Developers   []person `xml:"developers>developer"`
+		Contributors []person `xml:"contributors>contributor"`
+	}
+	if err := xml.Unmarshal([]byte(contents), &pom); err != nil {
+		return nil
+	}
+
+	var identities []fileIdentity
+	for _, p := range append(pom.Developers, pom.Contributors...) {
+		if p.Email != "" {
+			identities = append(identities, newFileIdentity(p.Name, p.Email))
+		}
+	}
+	return identities
+}
+
+// parseCargo returns the authors of a Rust package.
+func parseCargo(contents string) []fileIdentity {
+	var identities []fileIdentity
+	for _, m := range cargoAuthorsRe.FindAllStringSubmatch(contents, -1) {
+		for _, q := range quotedRe.FindAllStringSubmatch(m[1], -1) {
+			identities = append(identities, parseNameEmails(q[1])...)
+		}
+	}
+	return identities
+}
+
+// parseSetup returns the author and maintainer of a Python package.
+func parseSetup(contents string) []fileIdentity {
+	args := make(map[string]string)
+	for _, m := range setupRe.FindAllStringSubmatch(contents, -1) {
+		args[m[1]] = m[2]
+	}
+
+	var identities []fileIdentity
+	for _, role := range []string{"author", "maintainer"} {
+		// a single argument may list several comma-separated emails
+		for _, email := range strings.Split(args[role+"_email"], ",") {
+			if email = strings.TrimSpace(email); strings.Contains(email, "@") {
+				identities = append(identities, newFileIdentity(args[role], email))
+			}
+		}
+	}
+	return identities
+}
+
+// parseDebianControl returns the maintainers and uploaders of a Debian
+// package.
+func parseDebianControl(contents string) []fileIdentity {
+	var identities []fileIdentity
+	for _, m := range debianFieldRe.FindAllStringSubmatch(contents, -1) {
+		identities = append(identities, parseNameEmails(m[1])...)
+	}
+	return identities
+}
+
+func newFileIdentity(name, email string) fileIdentity {
+	name = strings.Trim(strings.TrimSpace(name), `"'`)
+	return fileIdentity{Name: name, Email: strings.TrimSpace(email)}
+}
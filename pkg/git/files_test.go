@@ -0,0 +1,80 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestCollectFileIdentities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authors := []byte("Jane Doe <jane@example.com>\n")
+	header := []byte("// Copyright 2020 John Roe <john@example.com>\n" + strings.Repeat("//\n", 2*headerSize))
+	files := map[string][]byte{
+		"AUTHORS":        authors,
+		"docs/AUTHORS":   authors,
+		"main.go":        header,
+		"vendor/main.go": header,
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signature := &object.Signature{Name: "a", Email: "a@example.com", When: time.Now()}
+	if _, err := worktree.Commit("files", &git.CommitOptions{Author: signature, Committer: signature}); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := make(CommitMetadata)
+	if err := CollectFileIdentities(repo, metadata, false); err != nil {
+		t.Fatalf("CollectFileIdentities: %v", err)
+	}
+
+	want := map[Metadata]string{
+		{Email: "jane@example.com", Name: "Jane Doe"}: "AUTHORS docs/AUTHORS",
+		{Email: "john@example.com", Name: "John Roe"}: "main.go vendor/main.go",
+	}
+	for k, paths := range want {
+		p, ok := metadata[k]
+		if !ok {
+			t.Errorf("identity %s not found", k.Email)
+			continue
+		}
+		var got []string
+		for path := range p.files {
+			got = append(got, path)
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != paths {
+			t.Errorf("files of %s = %v, want %s", k.Email, got, paths)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// forkRefPrefix is the prefix of the refs the branches of forks are fetched
+// into, followed by a key of the fork URL.
+const forkRefPrefix = "refs/forks/"
+
+// Upstream is the history of a repository forks are compared to.
+type Upstream struct {
+	commits    map[plumbing.Hash]bool
+	identities CommitMetadata
+}
+
+// NewUpstream collects the commits and identities reachable from the
+// references of the repository. Fetched forks are not part of it.
+func NewUpstream(repo *git.Repository) (*Upstream, error) {
+	metadata, _, err := collectMetadata(repo, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream := &Upstream{commits: make(map[plumbing.Hash]bool), identities: metadata}
+	for _, p := range metadata {
+		for hash := range p.commits {
+			upstream.commits[plumbing.NewHash(hash)] = true
+		}
+	}
+	return upstream, nil
+}
+
+// CollectForkMetadata fetches the branches of the fork into the upstream
+// repository and returns the identities of the commits of the fork that are
+// not in the upstream history, leaving out identities seen upstream. The
+// fetched branches are removed afterwards, cached clones keep only their own
+// references.
+func CollectForkMetadata(ctx context.Context, repo *git.Repository, upstream *Upstream,
+	forkURL string) (CommitMetadata, error) {
+	sum := sha256.Sum256([]byte(normalizeURL(forkURL)))
+	prefix := forkRefPrefix + hex.EncodeToString(sum[:8]) + "/"
+	defer removeRefs(repo, prefix)
+
+	remote := git.NewRemote(repo.Storer, &config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{forkURL},
+	})
+	err := remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs:        []config.RefSpec{config.RefSpec("+refs/heads/*:" + prefix + "*")},
+		Tags:            git.NoTags,
+		Force:           true,
+		InsecureSkipTLS: true,
+		Auth:            auth,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(CommitMetadata)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if ref.Type() != plumbing.HashReference || !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		c, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		branch := plumbing.NewBranchReferenceName(strings.TrimPrefix(name, prefix)).String()
+		return object.NewCommitPreorderIter(c, upstream.commits, nil).ForEach(func(c *object.Commit) error {
+			addCommit(metadata, c, branch, true)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range metadata {
+		if _, ok := upstream.identities[k]; ok {
+			delete(metadata, k)
+		}
+	}
+	return metadata, nil
+}
+
+// removeRefs deletes the references starting with the prefix.
+func removeRefs(repo *git.Repository, prefix string) {
+	refs, err := repo.References()
+	if err != nil {
+		return
+	}
+	var names []plumbing.ReferenceName
+	refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), prefix) {
+			names = append(names, ref.Name())
+		}
+		return nil
+	})
+	for _, name := range names {
+		repo.Storer.RemoveReference(name)
+	}
+}
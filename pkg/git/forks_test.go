@@ -0,0 +1,45 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestCollectForkMetadata(t *testing.T) {
+	forkDir, _ := exposedRepo(t, "a@example.com", "b@example.com")
+
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream, err := NewUpstream(repo)
+	if err != nil {
+		t.Fatalf("NewUpstream: %v", err)
+	}
+
+	metadata, err := CollectForkMetadata(context.Background(), repo, upstream, forkDir)
+	if err != nil {
+		t.Fatalf("CollectForkMetadata: %v", err)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, ok := metadata[Metadata{Email: email, Name: email}]; !ok {
+			t.Errorf("identity %s not found", email)
+		}
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), forkRefPrefix) {
+			t.Errorf("fork reference %s left in the repository", ref.Name())
+		}
+		return nil
+	})
+}
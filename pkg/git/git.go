@@ -6,7 +6,6 @@ import (
 	"encoding/hex"
 	"io/ioutil"
 	"os"
-	"os/signal"
 	"sync"
 	"time"
 
@@ -109,15 +108,18 @@ func createRemote(repo *git.Repository, remoteURL string) (*git.Repository, erro
 	return repo, nil
 }
 
-func pushInMemoryRepo(repo *git.Repository) error {
-	return repo.Push(&git.PushOptions{
+func pushInMemoryRepo(ctx context.Context, repo *git.Repository, force bool) error {
+	return repo.PushContext(ctx, &git.PushOptions{
 		RemoteName:      remoteName,
+		Force:           force,
 		InsecureSkipTLS: true,
 		Auth:            auth,
 	})
 }
 
-func CreateRemoteRepo(emails []string, remoteURL string) error {
+// CreateRemoteRepo pushes a commit authored by each email to the remote
+// repository. With force, the history of a reused remote is replaced.
+func CreateRemoteRepo(ctx context.Context, emails []string, remoteURL string, force bool) error {
 	repo, err := createInMemoryRepo(emails)
 	if err != nil {
 		return err
@@ -128,21 +130,36 @@ func CreateRemoteRepo(emails []string, remoteURL string) error {
 		return err
 	}
 
-	return pushInMemoryRepo(repo)
+	return pushInMemoryRepo(ctx, repo, force)
 }
 
-func CloneRepo(context context.Context, cloneURL string) (*git.Repository, string, error) {
+// CloneRepo clones the repository into a temporary directory, or into the
+// cache directory if one is set, in which case an already cached repository
+// is fetched instead.
+func CloneRepo(ctx context.Context, cloneURL string) (*git.Repository, string, error) {
+	if IsCached() {
+		repo, dir, err := cloneCached(ctx, cloneURL)
+		if err == nil && fetchPulls {
+			err = fetchPullRefs(ctx, repo)
+		}
+		return repo, dir, err
+	}
+
 	dir, err := ioutil.TempDir(os.TempDir(), tempDirPrefix)
 	if err != nil {
 		return nil, "", err
 	}
 
-	repo, err := git.PlainCloneContext(context, dir, true, &git.CloneOptions{
+	repo, err := git.PlainCloneContext(ctx, dir, true, &git.CloneOptions{
 		URL:             cloneURL,
 		InsecureSkipTLS: true,
 		Auth:            auth,
 	})
+	if err == nil && fetchPulls {
+		err = fetchPullRefs(ctx, repo)
+	}
 	if err != nil {
+		os.RemoveAll(dir)
 		return nil, "", err
 	}
 
@@ -150,26 +167,182 @@ func CloneRepo(context context.Context, cloneURL string) (*git.Repository, strin
 }
 
 func CollectMetadata(repo *git.Repository) (CommitMetadata, error) {
+	metadata, _, err := collectMetadata(repo, nil)
+	return metadata, err
+}
+
+// collectMetadata collects the metadata of the commits reachable from the
+// references, except the known commits and their ancestors. It also returns
+// the commit or tag of every reference.
+func collectMetadata(repo *git.Repository, known map[plumbing.Hash]bool) (CommitMetadata, map[string]string, error) {
 	refs, err := repo.References()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	ignore, err := shallowParents(repo)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	metadata := make(CommitMetadata)
+	tips := make(map[string]string)
+	var pulls []*plumbing.Reference
 	err = refs.ForEach(func(ref *plumbing.Reference) error {
-		if ref.Type() == plumbing.HashReference &&
-			(ref.Name().IsRemote() || ref.Name().IsBranch()) {
-			cIter, err := repo.Log(&git.LogOptions{From: ref.Hash()})
-			if err != nil {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		tips[ref.Name().String()] = ref.Hash().String()
+		if known[ref.Hash()] {
+			return nil
+		}
+
+		from := ref.Hash()
+		if ref.Name().IsTag() {
+			commit, ok, err := resolveTag(repo, metadata, ref)
+			if err != nil || !ok {
 				return err
 			}
-			err = cIter.ForEach(func(c *object.Commit) error {
-				metadata[Metadata{Email: c.Author.Email, Name: c.Author.Name}] = struct{}{}
-				metadata[Metadata{Email: c.Committer.Email, Name: c.Committer.Name}] = struct{}{}
-				return nil
-			})
+			from = commit
+		} else if pullNumber(ref.Name()) != 0 {
+			pulls = append(pulls, ref)
+			return nil
+		} else if !ref.Name().IsRemote() && !ref.Name().IsBranch() {
+			return nil
+		}
+
+		return collectCommits(repo, metadata, from, ref.Name().String(), known, ignore)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := collectPulls(repo, metadata, pulls, known, ignore); err != nil {
+		return nil, nil, err
+	}
+
+	// a broken .mailmap must not discard the collected metadata
+	if mailmap, err := ReadMailmap(repo); err == nil {
+		ApplyMailmap(metadata, mailmap)
+	}
+
+	return metadata, tips, nil
+}
+
+func collectCommits(repo *git.Repository, metadata CommitMetadata, from plumbing.Hash, refName string,
+	known map[plumbing.Hash]bool, ignore []plumbing.Hash) error {
+	c, err := repo.CommitObject(from)
+	if err != nil {
+		return err
+	}
+	cIter := object.NewCommitPreorderIter(c, known, ignore)
+	return cIter.ForEach(func(c *object.Commit) error {
+		addCommit(metadata, c, refName, true)
+		return nil
+	})
+}
+
+// shallowParents returns the parents of the shallow commits that are missing
+// from the repository, the boundary of a shallow clone or of a partially
+// recovered dump. Walks skip them instead of failing.
+func shallowParents(repo *git.Repository) ([]plumbing.Hash, error) {
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []plumbing.Hash
+	for _, hash := range shallow {
+		c, err := repo.CommitObject(hash)
+		if err != nil {
+			continue
+		}
+		for _, parent := range c.ParentHashes {
+			if repo.Storer.HasEncodedObject(parent) != nil {
+				missing = append(missing, parent)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// addCommit records the author, committer and trailer identities of the
+// commit and returns them. Empty refName means the commit was not reached
+// from a reference.
+func addCommit(metadata CommitMetadata, c *object.Commit, refName string, reachable bool) []*Provenance {
+	hash := c.Hash.String()
+	// the author goes first, its time is the one kept in the activity
+	// profile when the author also committed
+	author := metadata.Add(c.Author, RoleAuthor, hash)
+	committer := metadata.Add(c.Committer, RoleCommitter, hash)
+	committer.addSignature(c.PGPSignature)
+	identities := []*Provenance{author, committer}
+	for _, t := range parseTrailers(c.Message) {
+		sig := object.Signature{Name: t.Name, Email: t.Email, When: c.Author.When}
+		identities = append(identities, metadata.Add(sig, t.Role, hash))
+	}
+
+	for _, p := range identities {
+		if refName != "" {
+			p.AddRef(refName)
+		}
+		p.reached(hash, reachable)
+	}
+	return identities
+}
+
+// resolveTag records the taggers of the tag reference, following nested
+// annotated tags, and returns the commit the tag points to. The second return
+// value is false if the tag does not point to a commit.
+func resolveTag(repo *git.Repository, metadata CommitMetadata, ref *plumbing.Reference) (plumbing.Hash, bool, error) {
+	hash := ref.Hash()
+	for {
+		tag, err := repo.TagObject(hash)
+		if err == plumbing.ErrObjectNotFound {
+			break
+		}
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+
+		tagger := metadata.Add(tag.Tagger, RoleTagger, "")
+		tagger.AddTag(ref.Name().Short())
+		tagger.reached("", true)
+		tagger.addSignature(tagSignature(tag.PGPSignature, tag.Message))
+		hash = tag.Target
+	}
+
+	_, err := repo.CommitObject(hash)
+	if err == plumbing.ErrObjectNotFound {
+		return plumbing.ZeroHash, false, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	return hash, true, nil
+}
+
+// CollectTags returns the tags of the repository together with the tagger
+// identities of the annotated ones.
+func CollectTags(repo *git.Repository) ([]*Tag, error) {
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []*Tag
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		t := &Tag{Name: ref.Name().Short(), Target: ref.Hash().String()}
+		tag, err := repo.TagObject(ref.Hash())
+		if err == nil {
+			t.Target = tag.Target.String()
+			t.Tagger = tag.Tagger.Name
+			t.Email = tag.Tagger.Email
+			t.Date = &tag.Tagger.When
+		} else if err != plumbing.ErrObjectNotFound {
 			return err
 		}
+		tags = append(tags, t)
 		return nil
 	})
 
@@ -177,12 +350,20 @@ func CollectMetadata(repo *git.Repository) (CommitMetadata, error) {
 		return nil, err
 	}
 
-	return metadata, nil
+	return tags, nil
 }
 
+// OpenRepos opens local repositories. Paths of git bundles, packfiles and
+// zip or tar archives of repositories are read without a working copy.
 func OpenRepos(paths []string) []OpenResult {
 	var repos []OpenResult
 	for _, path := range paths {
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			if results, ok := openInput(path); ok {
+				repos = append(repos, results...)
+				continue
+			}
+		}
 		repo, err := git.PlainOpen(path)
 		repos = append(repos, OpenResult{Origin: path, Repo: repo, Error: err, Dir: path})
 	}
@@ -190,11 +371,10 @@ func OpenRepos(paths []string) []OpenResult {
 	return repos
 }
 
-func CloneRepos(urls []string, threads int) <-chan OpenResult {
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	ctx := context.Background()
-
+// CloneRepos clones the repositories concurrently. Once the context is
+// cancelled no more repositories are cloned and the results of interrupted
+// clones are dropped with their directories.
+func CloneRepos(ctx context.Context, urls []string, threads int) <-chan OpenResult {
 	var wg sync.WaitGroup
 	wg.Add(threads)
 
@@ -207,16 +387,34 @@ func CloneRepos(urls []string, threads int) <-chan OpenResult {
 			resultCh chan<- OpenResult) {
 			defer wg.Done()
 			for url := range urlsCh {
+				if ctx.Err() != nil {
+					return
+				}
 				repo, dir, err := CloneRepo(ctx, url)
 				if ctx.Err() != nil {
+					if err == nil && !IsCached() {
+						os.RemoveAll(dir)
+					}
 					return
 				}
-				resultCh <- OpenResult{Origin: url, Repo: repo, Dir: dir, Error: err}
+				result := OpenResult{Origin: url, Repo: repo, Dir: dir, Error: err}
+				if !IsCached() {
+					result.TempDir = dir
+				}
+				resultCh <- result
 			}
 		}(ctx, &wg, urlsCh, resultCh)
 	}
 
+	// equivalent URLs share a cache entry and are cloned once
+	queued := make(map[string]struct{})
 	for _, url := range urls {
+		if IsCached() {
+			if _, ok := queued[cachePath(url)]; ok {
+				continue
+			}
+			queued[cachePath(url)] = struct{}{}
+		}
 		urlsCh <- url
 	}
 	close(urlsCh)
@@ -229,11 +427,7 @@ func CloneRepos(urls []string, threads int) <-chan OpenResult {
 	return resultCh
 }
 
-func AnalyseRepos(repos []*git.Repository, threads int) <-chan AnalyseResult {
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	ctx := context.Background()
-
+func AnalyseRepos(ctx context.Context, repos []*git.Repository, threads int) <-chan AnalyseResult {
 	var wg sync.WaitGroup
 	wg.Add(threads)
 
@@ -0,0 +1,341 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+var (
+	ErrInvalidBundle  = errors.New("invalid bundle header")
+	ErrNoRepositories = errors.New("no repositories found")
+)
+
+// packRefPrefix is the prefix of the branches created for commits of a
+// packfile that no other commit of the packfile references.
+const packRefPrefix = "refs/heads/pack/"
+
+// openInput opens the repository stored in the file: a git bundle, a
+// packfile or an archive of repositories. The second return value is false
+// if the file is not of a supported type.
+func openInput(path string) ([]OpenResult, bool) {
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".bundle"):
+		repo, err := OpenBundle(path)
+		return []OpenResult{{Origin: path, Repo: repo, Error: err}}, true
+	case strings.HasSuffix(name, ".pack"):
+		repo, err := OpenPackfile(path)
+		return []OpenResult{{Origin: path, Repo: repo, Error: err}}, true
+	case strings.HasSuffix(name, ".zip"),
+		strings.HasSuffix(name, ".tar"),
+		strings.HasSuffix(name, ".tar.gz"),
+		strings.HasSuffix(name, ".tgz"),
+		strings.HasSuffix(name, ".tar.bz2"),
+		strings.HasSuffix(name, ".tbz2"):
+		return OpenArchive(path), true
+	}
+	return nil, false
+}
+
+// OpenBundle reads a git bundle (v2 or v3) into an in-memory repository.
+// Bundles with prerequisites can only be read if their packfile is not thin.
+func OpenBundle(path string) (*git.Repository, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	header = strings.TrimSpace(header)
+	if header != "# v2 git bundle" && header != "# v3 git bundle" {
+		return nil, ErrInvalidBundle
+	}
+
+	var refs []*plumbing.Reference
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		// capabilities (v3) and prerequisites
+		if strings.HasPrefix(line, "@") || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 || !plumbing.IsHash(parts[0]) {
+			return nil, ErrInvalidBundle
+		}
+		refs = append(refs, plumbing.NewHashReference(plumbing.ReferenceName(parts[1]),
+			plumbing.NewHash(parts[0])))
+	}
+
+	repo, storer, err := newMemoryRepo()
+	if err != nil {
+		return nil, err
+	}
+	if err := packfile.UpdateObjectStorage(storer, r); err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if err := storer.SetReference(ref); err != nil {
+			return nil, err
+		}
+	}
+	return repo, nil
+}
+
+// OpenPackfile reads a packfile into an in-memory repository. Packfiles carry
+// no references, so a branch is created for every commit that is not a
+// parent of another commit and a tag for every annotated tag.
+func OpenPackfile(path string) (*git.Repository, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	repo, storer, err := newMemoryRepo()
+	if err != nil {
+		return nil, err
+	}
+	if err := packfile.UpdateObjectStorage(storer, f); err != nil {
+		return nil, err
+	}
+	if err := createTipRefs(repo, packRefPrefix); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func newMemoryRepo() (*git.Repository, *memory.Storage, error) {
+	storer := memory.NewStorage()
+	repo, err := git.Init(storer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, storer, nil
+}
+
+// createTipRefs creates a branch under prefix for every commit that neither a
+// reference nor another commit points to, and a tag for every annotated tag
+// object without a tag reference.
+func createTipRefs(repo *git.Repository, prefix string) error {
+	commits, err := repo.CommitObjects()
+	if err != nil {
+		return err
+	}
+
+	tips := make(map[plumbing.Hash]struct{})
+	referenced := make(map[plumbing.Hash]struct{})
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			referenced[ref.Hash()] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = commits.ForEach(func(c *object.Commit) error {
+		tips[c.Hash] = struct{}{}
+		for _, p := range c.ParentHashes {
+			referenced[p] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for hash := range tips {
+		if _, ok := referenced[hash]; ok {
+			continue
+		}
+		ref := plumbing.NewHashReference(plumbing.ReferenceName(prefix+hash.String()), hash)
+		if err := repo.Storer.SetReference(ref); err != nil {
+			return err
+		}
+	}
+
+	tags, err := repo.TagObjects()
+	if err != nil {
+		return err
+	}
+	return tags.ForEach(func(t *object.Tag) error {
+		name := plumbing.NewTagReferenceName(t.Name)
+		if _, err := repo.Storer.Reference(name); err == nil {
+			return nil
+		}
+		return repo.Storer.SetReference(plumbing.NewHashReference(name, t.Hash))
+	})
+}
+
+// OpenArchive extracts a zip or tar archive into a temporary directory and
+// opens every repository found in it. Origins of the results are relative
+// to the archive; the temporary directory is set as TempDir of each result.
+func OpenArchive(path string) []OpenResult {
+	dir, err := ioutil.TempDir(os.TempDir(), tempDirPrefix)
+	if err != nil {
+		return []OpenResult{{Origin: path, Error: err}}
+	}
+
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		err = extractZip(path, dir)
+	} else {
+		err = extractTar(path, dir)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return []OpenResult{{Origin: path, Error: fmt.Errorf("failed to extract: %s", err)}}
+	}
+
+	results := DiscoverRepos(dir)
+	if len(results) == 0 {
+		os.RemoveAll(dir)
+		return []OpenResult{{Origin: path, Error: ErrNoRepositories}}
+	}
+	for i := range results {
+		results[i].Root = path
+		results[i].TempDir = dir
+	}
+	return results
+}
+
+func extractZip(path, dir string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		target, err := extractPath(dir, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(target, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTar(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".tgz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case strings.HasSuffix(name, ".bz2"), strings.HasSuffix(name, ".tbz2"):
+		r = bzip2.NewReader(f)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := extractPath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractPath returns the path of the archive entry inside dir and rejects
+// entries escaping it.
+func extractPath(dir, name string) (string, error) {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if target != dir && !strings.HasPrefix(target, dir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal path in archive: %s", name)
+	}
+	return target, nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,145 @@
+package git
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const mailmapFile = ".mailmap"
+
+type mailmapEntry struct {
+	properName  string
+	properEmail string
+	commitName  string
+	commitEmail string
+}
+
+// Mailmap maps commit identities to canonical ones as described in
+// gitmailmap(5).
+type Mailmap []mailmapEntry
+
+// ReadMailmap reads the .mailmap file from the HEAD tree of the repository.
+// It returns an empty mailmap if the repository has no HEAD or no mailmap.
+func ReadMailmap(repo *git.Repository) (Mailmap, error) {
+	head, err := repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := commit.File(mailmapFile)
+	if err == object.ErrFileNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return ParseMailmap(contents), nil
+}
+
+// ParseMailmap parses the contents of a mailmap file. Malformed lines are
+// skipped.
+func ParseMailmap(contents string) Mailmap {
+	var mailmap Mailmap
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		var names, emails []string
+		for {
+			start := strings.Index(line, "<")
+			end := strings.Index(line, ">")
+			if start < 0 || end < start {
+				break
+			}
+			names = append(names, strings.TrimSpace(line[:start]))
+			emails = append(emails, strings.TrimSpace(line[start+1:end]))
+			line = line[end+1:]
+		}
+
+		switch len(emails) {
+		case 1:
+			// Proper Name <commit@email>
+			if names[0] != "" {
+				mailmap = append(mailmap, mailmapEntry{
+					properName:  names[0],
+					commitEmail: emails[0],
+				})
+			}
+		case 2:
+			// [Proper Name] <proper@email> [Commit Name] <commit@email>
+			mailmap = append(mailmap, mailmapEntry{
+				properName:  names[0],
+				properEmail: emails[0],
+				commitName:  names[1],
+				commitEmail: emails[1],
+			})
+		}
+	}
+	return mailmap
+}
+
+// Map returns the canonical name and email of the identity, names and
+// emails are matched case-insensitively as git does. Later entries
+// take precedence, and entries matching both name and email take
+// precedence over entries matching the email only.
+func (m Mailmap) Map(name, email string) (string, string) {
+	var match *mailmapEntry
+	for i := range m {
+		e := &m[i]
+		if !strings.EqualFold(e.commitEmail, email) {
+			continue
+		}
+		if e.commitName != "" && !strings.EqualFold(e.commitName, name) {
+			continue
+		}
+		if match == nil || e.commitName != "" || match.commitName == "" {
+			match = e
+		}
+	}
+
+	if match == nil {
+		return name, email
+	}
+	if match.properName != "" {
+		name = match.properName
+	}
+	if match.properEmail != "" {
+		email = match.properEmail
+	}
+	return name, email
+}
+
+// ApplyMailmap records the canonical identity of every identity in the
+// metadata that the mailmap maps to a different name or email. The
+// original identities are kept, so no evidence is lost.
+func ApplyMailmap(metadata CommitMetadata, mailmap Mailmap) {
+	if len(mailmap) == 0 {
+		return
+	}
+	for k, p := range metadata {
+		name, email := mailmap.Map(k.Name, k.Email)
+		if name != k.Name || email != k.Email {
+			p.canonical = &Metadata{Email: email, Name: name}
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package git
+
+import "testing"
+
+func TestMailmapMapIgnoresCase(t *testing.T) {
+	mailmap := ParseMailmap("Jane Doe <jane@example.com> jane doe <JDoe@Example.com>\n")
+
+	name, email := mailmap.Map("JANE DOE", "jdoe@example.com")
+	if name != "Jane Doe" || email != "jane@example.com" {
+		t.Errorf("Map = %s <%s>, want Jane Doe <jane@example.com>", name, email)
+	}
+	name, email = mailmap.Map("John Roe", "jdoe@example.com")
+	if name != "John Roe" || email != "jdoe@example.com" {
+		t.Errorf("Map = %s <%s>, want the identity unchanged", name, email)
+	}
+}
@@ -0,0 +1,192 @@
+package git
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// Artifacts are infrastructure and project-management references found in
+// commit messages.
+type Artifacts struct {
+	// Hosts are internal hostnames: names under internal suffixes and
+	// single-label hosts of URLs.
+	Hosts []string `json:"hosts,omitempty"`
+	// IPs are private, shared and link-local IPv4 and unique local IPv6
+	// addresses.
+	IPs     []string `json:"ips,omitempty"`
+	Tickets []string `json:"tickets,omitempty"`
+	URLs    []string `json:"urls,omitempty"`
+	// Emails are the addresses of people not otherwise seen in the
+	// repository.
+	Emails []string `json:"emails,omitempty"`
+}
+
+type artifactSet struct {
+	hosts   map[string]struct{}
+	ips     map[string]struct{}
+	tickets map[string]struct{}
+	urls    map[string]struct{}
+	emails  map[string]struct{}
+}
+
+var (
+	urlRe      = regexp.MustCompile(`\b[A-Za-z][A-Za-z0-9+.-]*://[^\s<>"'` + "`" + `]+`)
+	hostRe     = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+(?:local|localdomain|internal|intranet|corp|lan|private|priv)\b`)
+	ipv4Re     = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Re     = regexp.MustCompile(`(?i)\bf[cd][0-9a-f]{2}:[0-9a-f:]*[0-9a-f]`)
+	ticketRe   = regexp.MustCompile(`\b[A-Z][A-Z0-9]{1,9}-[1-9][0-9]{0,6}\b`)
+	msgEmailRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+)
+
+// Prefixes of "KEY-123" words that are not ticket keys.
+var ticketStopwords = map[string]bool{
+	"CVE": true, "CWE": true, "GHSA": true, "ISO": true, "RFC": true, "SHA": true,
+	"UTF": true, "UCS": true, "MD": true, "AES": true, "TLS": true, "SSL": true,
+	"HTTP": true, "IPV": true, "PEP": true, "ECMA": true, "ES": true, "CP": true,
+	"WIN": true, "COVID": true,
+}
+
+// privateNets are the address ranges reported as private.
+var privateNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10",
+		"169.254.0.0/16", "fc00::/7", "fe80::/10",
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+func newArtifactSet() *artifactSet {
+	return &artifactSet{
+		hosts:   make(map[string]struct{}),
+		ips:     make(map[string]struct{}),
+		tickets: make(map[string]struct{}),
+		urls:    make(map[string]struct{}),
+		emails:  make(map[string]struct{}),
+	}
+}
+
+// CollectMessageArtifacts extracts internal hostnames, private IPs, ticket
+// keys, URLs and email addresses from the messages of the commits found in
+// the metadata, and records them with the authors of the commits.
+func CollectMessageArtifacts(repo *git.Repository, metadata CommitMetadata) {
+	for _, c := range metadata.localCommits(repo) {
+		p, ok := metadata[Metadata{Email: c.Author.Email, Name: c.Author.Name}]
+		if !ok {
+			continue
+		}
+		if p.artifacts == nil {
+			p.artifacts = newArtifactSet()
+		}
+		p.artifacts.extract(c.Message)
+	}
+}
+
+func (a *artifactSet) extract(message string) {
+	for _, raw := range urlRe.FindAllString(message, -1) {
+		raw = strings.TrimRight(raw, ".,;:!?)]}")
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		a.urls[raw] = struct{}{}
+		host := strings.ToLower(u.Hostname())
+		if !strings.Contains(host, ".") && host != "localhost" {
+			a.hosts[host] = struct{}{}
+		}
+	}
+	for _, host := range hostRe.FindAllString(message, -1) {
+		a.hosts[strings.ToLower(host)] = struct{}{}
+	}
+
+	for _, s := range append(ipv4Re.FindAllString(message, -1), ipv6Re.FindAllString(message, -1)...) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			continue
+		}
+		for _, n := range privateNets {
+			if n.Contains(ip) {
+				a.ips[ip.String()] = struct{}{}
+				break
+			}
+		}
+	}
+
+	for _, ticket := range ticketRe.FindAllString(message, -1) {
+		if !ticketStopwords[ticket[:strings.IndexByte(ticket, '-')]] {
+			a.tickets[ticket] = struct{}{}
+		}
+	}
+
+	for _, email := range msgEmailRe.FindAllString(message, -1) {
+		a.emails[strings.ToLower(email)] = struct{}{}
+	}
+}
+
+func (a *artifactSet) merge(other *artifactSet) {
+	for _, pair := range [][2]map[string]struct{}{
+		{a.hosts, other.hosts},
+		{a.ips, other.ips},
+		{a.tickets, other.tickets},
+		{a.urls, other.urls},
+		{a.emails, other.emails},
+	} {
+		for k := range pair[1] {
+			pair[0][k] = struct{}{}
+		}
+	}
+}
+
+// convert returns the artifacts, leaving out the emails of known
+// identities. It returns nil if there are none.
+func (a *artifactSet) convert(known map[string]struct{}) *Artifacts {
+	var emails []string
+	for email := range a.emails {
+		if _, ok := known[email]; !ok {
+			emails = append(emails, email)
+		}
+	}
+	sort.Strings(emails)
+
+	artifacts := &Artifacts{
+		Hosts:   sortedKeys(a.hosts),
+		IPs:     sortedKeys(a.ips),
+		Tickets: sortedKeys(a.tickets),
+		URLs:    sortedKeys(a.urls),
+		Emails:  emails,
+	}
+	if len(artifacts.Hosts)+len(artifacts.IPs)+len(artifacts.Tickets)+len(artifacts.URLs)+len(emails) == 0 {
+		return nil
+	}
+	return artifacts
+}
+
+// knownEmails returns the lowercased emails of the identities of the
+// metadata.
+func knownEmails(metadata CommitMetadata) map[string]struct{} {
+	known := make(map[string]struct{})
+	for k := range metadata {
+		known[strings.ToLower(k.Email)] = struct{}{}
+	}
+	return known
+}
+
+// ConvertArtifacts returns the artifacts found in all commit messages of the
+// metadata, or nil if there are none.
+func ConvertArtifacts(metadata CommitMetadata) *Artifacts {
+	all := newArtifactSet()
+	for _, p := range metadata {
+		if p.artifacts != nil {
+			all.merge(p.artifacts)
+		}
+	}
+	return all.convert(knownEmails(metadata))
+}
@@ -0,0 +1,403 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func newProvenance() *Provenance {
+	return &Provenance{
+		roles:   make(map[string]struct{}),
+		commits: make(map[string]struct{}),
+		refs:    make(map[string]struct{}),
+		tags:    make(map[string]struct{}),
+		files:   make(map[string]struct{}),
+		pulls:   make(map[int]struct{}),
+		keys:    make(map[string]*SigningKey),
+		offsets: make(map[string]*TimezoneUsage),
+
+		unreachable: make(map[string]struct{}),
+	}
+}
+
+// Add records the identity of the signature seen in the given role of the
+// commit and returns its provenance. Empty hash means the identity was not
+// seen in a commit.
+func (m CommitMetadata) Add(sig object.Signature, role, hash string) *Provenance {
+	key := Metadata{Email: sig.Email, Name: sig.Name}
+	p, ok := m[key]
+	if !ok {
+		p = newProvenance()
+		m[key] = p
+	}
+
+	// commits are reached once per reference and usually authored and
+	// committed by the same identity, count them only once
+	_, known := p.commits[hash]
+	p.roles[role] = struct{}{}
+	if hash != "" {
+		p.commits[hash] = struct{}{}
+	}
+	p.seen(sig.When)
+	if !known && (role == RoleAuthor || role == RoleCommitter || role == RoleTagger) {
+		p.active(sig.When)
+	}
+	return p
+}
+
+// Merge adds all identities of other to the metadata.
+func (m CommitMetadata) Merge(other CommitMetadata) {
+	for k, v := range other {
+		if p, ok := m[k]; ok {
+			p.merge(v)
+		} else {
+			m[k] = v
+		}
+	}
+}
+
+// localCommits returns the commits of the metadata found in the repository,
+// sorted by hash.
+func (m CommitMetadata) localCommits(repo *git.Repository) []*object.Commit {
+	hashes := make(map[string]struct{})
+	for _, p := range m {
+		for hash := range p.commits {
+			hashes[hash] = struct{}{}
+		}
+	}
+
+	var commits []*object.Commit
+	for _, hash := range sortedKeys(hashes) {
+		c, err := repo.CommitObject(plumbing.NewHash(hash))
+		if err != nil {
+			// commits known only from the GitHub API
+			continue
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+// AddRef records the reference the identity was reached from.
+func (p *Provenance) AddRef(name string) {
+	p.refs[name] = struct{}{}
+}
+
+// AddFile records the repository file the identity was found in.
+func (p *Provenance) AddFile(path string) {
+	p.files[path] = struct{}{}
+}
+
+func (p *Provenance) addPull(number int) {
+	p.pulls[number] = struct{}{}
+}
+
+// AddTag records the tag the identity was seen in.
+func (p *Provenance) AddTag(name string) {
+	p.tags[name] = struct{}{}
+}
+
+// addSignature records the key of the armored signature made by the
+// identity. Malformed and unsupported signatures are ignored.
+func (p *Provenance) addSignature(armored string) {
+	if armored == "" {
+		return
+	}
+	key, err := parseSignature(armored)
+	if err != nil || key == nil {
+		return
+	}
+	p.addKey(key)
+}
+
+func (p *Provenance) addKey(key *SigningKey) {
+	id := key.Type + ":" + key.ID
+	k, ok := p.keys[id]
+	if !ok {
+		k = &SigningKey{Type: key.Type, ID: key.ID}
+		p.keys[id] = k
+	}
+	if key.Fingerprint != "" {
+		k.Fingerprint = key.Fingerprint
+	}
+	k.UserIDs = appendUnique(k.UserIDs, key.UserIDs...)
+}
+
+// reached records whether the identity was seen in a reachable object.
+// Empty hash means the identity was not seen in a commit.
+func (p *Provenance) reached(hash string, reachable bool) {
+	if reachable {
+		p.reachable = true
+	} else if hash != "" {
+		p.unreachable[hash] = struct{}{}
+	}
+}
+
+// isUnreachable reports whether the identity was only seen in unreachable
+// commits or in reflog entries.
+func (p *Provenance) isUnreachable() bool {
+	_, reflog := p.roles[RoleReflog]
+	return !p.reachable && (len(p.unreachable) != 0 || reflog)
+}
+
+func (p *Provenance) seen(when time.Time) {
+	if when.IsZero() {
+		return
+	}
+	if p.firstSeen.IsZero() || when.Before(p.firstSeen) {
+		p.firstSeen = when
+	}
+	if when.After(p.lastSeen) {
+		p.lastSeen = when
+	}
+}
+
+// active adds the time of a signature made by the identity itself to its
+// activity profile.
+func (p *Provenance) active(when time.Time) {
+	if when.IsZero() {
+		return
+	}
+	p.hours[when.Weekday()][when.Hour()]++
+	p.addOffset(&TimezoneUsage{Offset: when.Format("-0700"), Count: 1, First: when, Last: when})
+}
+
+func (p *Provenance) addOffset(usage *TimezoneUsage) {
+	u, ok := p.offsets[usage.Offset]
+	if !ok {
+		p.offsets[usage.Offset] = &TimezoneUsage{
+			Offset: usage.Offset,
+			Count:  usage.Count,
+			First:  usage.First,
+			Last:   usage.Last,
+		}
+		return
+	}
+	u.Count += usage.Count
+	if usage.First.Before(u.First) {
+		u.First = usage.First
+	}
+	if usage.Last.After(u.Last) {
+		u.Last = usage.Last
+	}
+}
+
+func (p *Provenance) activity() *Activity {
+	if len(p.offsets) == 0 {
+		return nil
+	}
+
+	activity := &Activity{Hours: p.hours}
+	for _, u := range p.offsets {
+		activity.Timezones = append(activity.Timezones, u)
+	}
+	sort.Slice(activity.Timezones, func(i, j int) bool {
+		return activity.Timezones[i].First.Before(activity.Timezones[j].First)
+	})
+
+	for i, a := range activity.Timezones {
+		for _, b := range activity.Timezones[i+1:] {
+			if b.First.Before(a.Last) && offsetDistance(a, b) > time.Hour {
+				activity.Overlapping = true
+			}
+		}
+	}
+	return activity
+}
+
+func offsetDistance(a, b *TimezoneUsage) time.Duration {
+	_, x := a.First.Zone()
+	_, y := b.First.Zone()
+	d := time.Duration(x-y) * time.Second
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func (p *Provenance) merge(other *Provenance) {
+	for k := range other.roles {
+		p.roles[k] = struct{}{}
+	}
+	for k := range other.commits {
+		p.commits[k] = struct{}{}
+	}
+	for k := range other.refs {
+		p.refs[k] = struct{}{}
+	}
+	for k := range other.tags {
+		p.tags[k] = struct{}{}
+	}
+	for k := range other.files {
+		p.files[k] = struct{}{}
+	}
+	for k := range other.pulls {
+		p.pulls[k] = struct{}{}
+	}
+	for _, k := range other.keys {
+		p.addKey(k)
+	}
+	for _, u := range other.offsets {
+		p.addOffset(u)
+	}
+	for d := range other.hours {
+		for h := range other.hours[d] {
+			p.hours[d][h] += other.hours[d][h]
+		}
+	}
+	if other.canonical != nil {
+		p.canonical = other.canonical
+	}
+	if other.artifacts != nil {
+		if p.artifacts == nil {
+			p.artifacts = newArtifactSet()
+		}
+		p.artifacts.merge(other.artifacts)
+	}
+	p.reachable = p.reachable || other.reachable
+	for k := range other.unreachable {
+		p.unreachable[k] = struct{}{}
+	}
+	p.seen(other.firstSeen)
+	p.seen(other.lastSeen)
+}
+
+// ConvertProvenance converts metadata to the list of identities sorted by
+// email and name.
+func ConvertProvenance(metadata CommitMetadata) []*Identity {
+	identities := make([]*Identity, 0, len(metadata))
+	known := knownEmails(metadata)
+	for k, p := range metadata {
+		var keys, signers []string
+		for id, key := range p.keys {
+			keys = append(keys, id)
+			signers = appendUnique(signers, key.UserIDs...)
+		}
+		sort.Strings(keys)
+		sort.Strings(signers)
+		var pulls []int
+		for n := range p.pulls {
+			pulls = append(pulls, n)
+		}
+		sort.Ints(pulls)
+		var canonical string
+		if p.canonical != nil {
+			canonical = fmt.Sprintf("%s <%s>", p.canonical.Name, p.canonical.Email)
+		}
+		identity := &Identity{
+			Email:        k.Email,
+			Name:         k.Name,
+			Canonical:    canonical,
+			Roles:        sortedKeys(p.roles),
+			Commits:      sortedKeys(p.commits),
+			Refs:         sortedKeys(p.refs),
+			Tags:         sortedKeys(p.tags),
+			PullRequests: pulls,
+			Keys:         keys,
+			Signers:      signers,
+			Files:        sortedKeys(p.files),
+			Activity:     p.activity(),
+
+			Unreachable:        p.isUnreachable(),
+			UnreachableCommits: sortedKeys(p.unreachable),
+		}
+		if p.artifacts != nil {
+			identity.Artifacts = p.artifacts.convert(known)
+		}
+		// identities found only in files have no dates
+		if !p.firstSeen.IsZero() {
+			firstSeen, lastSeen := p.firstSeen, p.lastSeen
+			identity.FirstSeen = &firstSeen
+			identity.LastSeen = &lastSeen
+		}
+		identities = append(identities, identity)
+	}
+
+	sort.Slice(identities, func(i, j int) bool {
+		if identities[i].Email != identities[j].Email {
+			return identities[i].Email < identities[j].Email
+		}
+		return identities[i].Name < identities[j].Name
+	})
+	return identities
+}
+
+// ConvertSources returns the files each email was found in, for emails
+// found in repository files.
+func ConvertSources(metadata CommitMetadata) map[string][]string {
+	sources := make(map[string][]string)
+	for k, p := range metadata {
+		if len(p.files) != 0 {
+			sources[k.Email] = appendUnique(sources[k.Email], sortedKeys(p.files)...)
+		}
+	}
+	for _, files := range sources {
+		sort.Strings(files)
+	}
+	return sources
+}
+
+// ConvertKeys returns the signing keys seen in the metadata together with
+// the identities that signed with them, so identities can be correlated by
+// key even when their names and emails differ.
+func ConvertKeys(metadata CommitMetadata) []*SigningKey {
+	keys := make(map[string]*SigningKey)
+	for k, p := range metadata {
+		for id, key := range p.keys {
+			sk, ok := keys[id]
+			if !ok {
+				sk = &SigningKey{Type: key.Type, ID: key.ID}
+				keys[id] = sk
+			}
+			if key.Fingerprint != "" {
+				sk.Fingerprint = key.Fingerprint
+			}
+			sk.UserIDs = appendUnique(sk.UserIDs, key.UserIDs...)
+			sk.Identities = appendUnique(sk.Identities, fmt.Sprintf("%s <%s>", k.Name, k.Email))
+		}
+	}
+
+	result := make([]*SigningKey, 0, len(keys))
+	for _, key := range keys {
+		sort.Strings(key.UserIDs)
+		sort.Strings(key.Identities)
+		result = append(result, key)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		contains := false
+		for _, v := range list {
+			if v == item {
+				contains = true
+				break
+			}
+		}
+		if !contains {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
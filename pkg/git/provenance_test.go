@@ -0,0 +1,36 @@
+package git
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestAddCommitActivity(t *testing.T) {
+	authored := time.Date(2020, 1, 1, 9, 0, 0, 0, time.FixedZone("", 9*3600))
+	committed := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	c := &object.Commit{
+		Hash:      plumbing.NewHash("0123456789012345678901234567890123456789"),
+		Author:    object.Signature{Name: "A", Email: "a@example.com", When: authored},
+		Committer: object.Signature{Name: "A", Email: "a@example.com", When: committed},
+		Message:   "rebased",
+	}
+
+	metadata := make(CommitMetadata)
+	// commits are reached once per reference
+	addCommit(metadata, c, "refs/heads/master", true)
+	addCommit(metadata, c, "refs/heads/topic", true)
+
+	activity := metadata[Metadata{Email: "a@example.com", Name: "A"}].activity()
+	if activity == nil || len(activity.Timezones) != 1 {
+		t.Fatalf("expected one timezone, got %+v", activity)
+	}
+	if tz := activity.Timezones[0]; tz.Offset != "+0900" || tz.Count != 1 {
+		t.Errorf("expected author offset +0900 once, got %s %d", tz.Offset, tz.Count)
+	}
+	if n := activity.Hours[authored.Weekday()][authored.Hour()]; n != 1 {
+		t.Errorf("expected the authored hour once, got %d", n)
+	}
+}
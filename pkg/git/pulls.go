@@ -0,0 +1,119 @@
+package git
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	pullRefPrefix = "refs/pull/"
+	// pullRefSpec fetches the head of every pull request, GitHub keeps them
+	// for closed and unmerged pull requests too.
+	pullRefSpec = "+refs/pull/*/head:refs/pull/*/head"
+)
+
+// fetchPulls enables fetching pull request refs after cloning.
+var fetchPulls bool
+
+// SetFetchPullRefs sets whether cloned repositories also fetch the heads of
+// their GitHub pull requests (refs/pull/<number>/head).
+func SetFetchPullRefs(enabled bool) {
+	fetchPulls = enabled
+}
+
+func fetchPullRefs(ctx context.Context, repo *git.Repository) error {
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName:      remoteName,
+		RefSpecs:        []config.RefSpec{pullRefSpec},
+		Force:           true,
+		InsecureSkipTLS: true,
+		Auth:            auth,
+	})
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+// pullNumber returns the number of a pull request head ref, or 0 for other
+// refs.
+func pullNumber(name plumbing.ReferenceName) int {
+	s := string(name)
+	if !strings.HasPrefix(s, pullRefPrefix) || !strings.HasSuffix(s, "/head") {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(s, pullRefPrefix), "/head"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// collectPulls records the commits of every pull request with its number.
+// The commits of a pull request are those reachable from its head and not
+// from the first-parent history of HEAD, the default branch pull requests
+// are merged into, so commits shared by stacked pull requests are recorded
+// with each of them. Known commits and their ancestors are skipped.
+func collectPulls(repo *git.Repository, metadata CommitMetadata, pulls []*plumbing.Reference,
+	known map[plumbing.Hash]bool, ignore []plumbing.Hash) error {
+	if len(pulls) == 0 {
+		return nil
+	}
+
+	base := mainline(repo)
+	for hash := range known {
+		base[hash] = true
+	}
+
+	for _, ref := range pulls {
+		if base[ref.Hash()] {
+			continue
+		}
+		c, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+
+		number := pullNumber(ref.Name())
+		err = object.NewCommitPreorderIter(c, base, ignore).ForEach(func(c *object.Commit) error {
+			for _, p := range addCommit(metadata, c, ref.Name().String(), true) {
+				p.addPull(number)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mainline returns the first-parent history of HEAD. It is empty if HEAD
+// does not point to a commit.
+func mainline(repo *git.Repository) map[plumbing.Hash]bool {
+	commits := make(map[plumbing.Hash]bool)
+	head, err := repo.Head()
+	if err != nil {
+		return commits
+	}
+
+	for hash := head.Hash(); ; {
+		c, err := repo.CommitObject(hash)
+		if err != nil {
+			// missing parent of a shallow or partially recovered repository
+			break
+		}
+		commits[hash] = true
+		if c.NumParents() == 0 {
+			break
+		}
+		hash = c.ParentHashes[0]
+	}
+	return commits
+}
@@ -0,0 +1,100 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// storeCommit stores a commit with an empty tree by the email.
+func storeCommit(t *testing.T, repo *git.Repository, email string, parents ...plumbing.Hash) plumbing.Hash {
+	tree := repo.Storer.NewEncodedObject()
+	if err := (&object.Tree{}).Encode(tree); err != nil {
+		t.Fatal(err)
+	}
+	treeHash, err := repo.Storer.SetEncodedObject(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := object.Signature{Name: email, Email: email, When: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	commit := &object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      email,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func setRef(t *testing.T, repo *git.Repository, name string, hash plumbing.Hash) {
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), hash)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectPulls(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// master: a - b - merge of #1 (f1 - f2), #2 is stacked on #1 and #3
+	// is an unmerged pull request on a
+	a := storeCommit(t, repo, "a@example.com")
+	b := storeCommit(t, repo, "b@example.com", a)
+	f1 := storeCommit(t, repo, "f1@example.com", a)
+	f2 := storeCommit(t, repo, "f2@example.com", f1)
+	merge := storeCommit(t, repo, "merge@example.com", b, f2)
+	g := storeCommit(t, repo, "g@example.com", f2)
+	h := storeCommit(t, repo, "h@example.com", a)
+	setRef(t, repo, "refs/heads/master", merge)
+	setRef(t, repo, "refs/pull/1/head", f2)
+	setRef(t, repo, "refs/pull/2/head", g)
+	setRef(t, repo, "refs/pull/3/head", h)
+
+	metadata, err := CollectMetadata(repo)
+	if err != nil {
+		t.Fatalf("CollectMetadata: %v", err)
+	}
+
+	want := map[string]string{
+		"a@example.com":     "[]",
+		"b@example.com":     "[]",
+		"merge@example.com": "[]",
+		"f1@example.com":    "[1 2]",
+		"f2@example.com":    "[1 2]",
+		"g@example.com":     "[2]",
+		"h@example.com":     "[3]",
+	}
+	for email, pulls := range want {
+		p, ok := metadata[Metadata{Email: email, Name: email}]
+		if !ok {
+			t.Errorf("identity %s not found", email)
+			continue
+		}
+		var numbers []int
+		for n := range p.pulls {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		if got := fmt.Sprint(numbers); got != pulls {
+			t.Errorf("pull requests of %s = %s, want %s", email, got, pulls)
+		}
+	}
+}
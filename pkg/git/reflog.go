@@ -0,0 +1,43 @@
+package git
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// reflogEntry is a single line of a reflog file:
+// "<old> <new> Name <email> <timestamp> <tz>\t<message>".
+type reflogEntry struct {
+	Old       plumbing.Hash
+	New       plumbing.Hash
+	Signature object.Signature
+	Message   string
+}
+
+// parseReflog parses the contents of a reflog file. Malformed lines are
+// skipped.
+func parseReflog(contents string) []reflogEntry {
+	var entries []reflogEntry
+	for _, line := range strings.Split(contents, "\n") {
+		var message string
+		if i := strings.Index(line, "\t"); i >= 0 {
+			line, message = line[:i], line[i+1:]
+		}
+
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) != 3 || !plumbing.IsHash(parts[0]) || !plumbing.IsHash(parts[1]) {
+			continue
+		}
+
+		entry := reflogEntry{
+			Old:     plumbing.NewHash(parts[0]),
+			New:     plumbing.NewHash(parts[1]),
+			Message: message,
+		}
+		entry.Signature.Decode([]byte(parts[2]))
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,129 @@
+package git
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Access statuses of a remote repository.
+const (
+	AccessOK           = "ok"
+	AccessEmpty        = "empty"
+	AccessAuthRequired = "auth-required"
+	AccessDenied       = "denied"
+	AccessNotFound     = "not-found"
+	AccessError        = "error"
+)
+
+// RemoteRefs are the references advertised by a remote repository.
+type RemoteRefs struct {
+	Access string `json:"access"`
+	// Head is the branch HEAD points to.
+	Head string       `json:"head,omitempty"`
+	Refs []*RemoteRef `json:"refs,omitempty"`
+}
+
+type RemoteRef struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+type RemoteResult struct {
+	Origin string
+	Refs   *RemoteRefs
+	Error  error
+}
+
+// ListRemote lists the references of the remote repository without
+// fetching any object, like git ls-remote. Access is set even if an error
+// is returned.
+func ListRemote(url string) (*RemoteRefs, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{url},
+	})
+	refs, err := remote.List(&git.ListOptions{Auth: auth, InsecureSkipTLS: true})
+	switch err {
+	case nil:
+	case transport.ErrEmptyRemoteRepository:
+		return &RemoteRefs{Access: AccessEmpty}, nil
+	case transport.ErrAuthenticationRequired:
+		return &RemoteRefs{Access: AccessAuthRequired}, err
+	case transport.ErrAuthorizationFailed:
+		return &RemoteRefs{Access: AccessDenied}, err
+	case transport.ErrRepositoryNotFound:
+		return &RemoteRefs{Access: AccessNotFound}, err
+	default:
+		return &RemoteRefs{Access: AccessError}, err
+	}
+
+	result := &RemoteRefs{Access: AccessOK}
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD {
+			head = ref
+			continue
+		}
+		if ref.Type() == plumbing.HashReference {
+			result.Refs = append(result.Refs, &RemoteRef{Name: ref.Name().String(), Hash: ref.Hash().String()})
+		}
+	}
+	sort.Slice(result.Refs, func(i, j int) bool {
+		return result.Refs[i].Name < result.Refs[j].Name
+	})
+
+	// servers without the symref capability advertise HEAD as a hash
+	if head != nil && head.Type() == plumbing.SymbolicReference {
+		result.Head = head.Target().String()
+	} else if head != nil {
+		for _, ref := range result.Refs {
+			if plumbing.ReferenceName(ref.Name).IsBranch() && ref.Hash == head.Hash().String() {
+				result.Head = ref.Name
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// ListRemotes lists the references of the remote repositories concurrently
+// until the context is cancelled.
+func ListRemotes(ctx context.Context, urls []string, threads int) <-chan RemoteResult {
+	var wg sync.WaitGroup
+	wg.Add(threads)
+
+	resultCh := make(chan RemoteResult, len(urls))
+	urlsCh := make(chan string, len(urls))
+
+	for i := 0; i < threads; i++ {
+		go func() {
+			defer wg.Done()
+			for url := range urlsCh {
+				if ctx.Err() != nil {
+					return
+				}
+				refs, err := ListRemote(url)
+				resultCh <- RemoteResult{Origin: url, Refs: refs, Error: err}
+			}
+		}()
+	}
+
+	for _, url := range urls {
+		urlsCh <- url
+	}
+	close(urlsCh)
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	return resultCh
+}
@@ -0,0 +1,257 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// maxPreviewLength is the maximum length of the line shown around a secret.
+const maxPreviewLength = 120
+
+// Rule detects a kind of secret in added lines. If Pattern has a capturing
+// group, the first group is the secret, otherwise the whole match is. Matches
+// whose secret has a Shannon entropy (bits per character) below Entropy are
+// ignored.
+type Rule struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description,omitempty"`
+	Pattern     string  `json:"pattern"`
+	Entropy     float64 `json:"entropy,omitempty"`
+	re          *regexp.Regexp
+}
+
+// Secret is a match of a rule in a line added by a commit.
+type Secret struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path"`
+	Commit  string `json:"commit"`
+	Author  string `json:"author,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Preview string `json:"preview"`
+}
+
+// DefaultRules are the rules used when no rules file is given.
+var DefaultRules = []*Rule{
+	newRule("aws-access-key-id", "AWS access key ID", `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`, 0),
+	newRule("aws-secret-access-key", "AWS secret access key",
+		`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`, 3.5),
+	newRule("github-token", "GitHub token", `\b(gh[pousr]_[A-Za-z0-9]{36,})\b`, 0),
+	newRule("github-fine-grained-token", "GitHub fine-grained token", `\b(github_pat_[A-Za-z0-9_]{80,})\b`, 0),
+	newRule("gitlab-token", "GitLab personal access token", `\b(glpat-[A-Za-z0-9_-]{20})\b`, 0),
+	newRule("slack-token", "Slack token", `\b(xox[abposr]-[A-Za-z0-9-]{10,})`, 0),
+	newRule("slack-webhook", "Slack incoming webhook", `(https://hooks\.slack\.com/services/[A-Za-z0-9_/]+)`, 0),
+	newRule("google-api-key", "Google API key", `\b(AIza[0-9A-Za-z_-]{35})`, 0),
+	newRule("stripe-key", "Stripe live key", `\b((?:sk|rk)_live_[0-9A-Za-z]{24,})`, 0),
+	newRule("private-key", "Private key", `-----BEGIN (?:[A-Z]+ )?PRIVATE KEY(?: BLOCK)?-----`, 0),
+	newRule("jwt", "JSON Web Token", `\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`, 0),
+	newRule("url-password", "Password in URL", `[A-Za-z][A-Za-z0-9+.-]*://[^/\s:@'"]+:([^/\s:@'"]{3,})@`, 0),
+	newRule("generic-secret", "Password, secret, token or API key assignment",
+		`(?i)(?:password|passwd|pwd|secret|token|api_?key)["']?\s*[:=]\s*["']([^"'\s]{8,})["']`, 3),
+}
+
+func newRule(id, description, pattern string, entropy float64) *Rule {
+	return &Rule{
+		ID:          id,
+		Description: description,
+		Pattern:     pattern,
+		Entropy:     entropy,
+		re:          regexp.MustCompile(pattern),
+	}
+}
+
+// LoadRules reads rules from a JSON file containing an array of rules.
+func LoadRules(path string) ([]*Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules file '%s': (%s)", path, err.Error())
+	}
+	for _, rule := range rules {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("invalid rules file '%s': (rule without id)", path)
+		}
+		rule.re, err = regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of rule '%s': (%s)", rule.ID, err.Error())
+		}
+	}
+	return rules, nil
+}
+
+// ScanSecrets matches the rules against the lines added by every commit
+// found in the metadata. Merge commits are skipped, as their changes are
+// introduced by their parents. The author of a secret is the canonical
+// identity of the commit author if a mailmap maps it.
+func ScanSecrets(repo *git.Repository, metadata CommitMetadata, rules []*Rule) ([]*Secret, error) {
+	var secrets []*Secret
+	var failed []string
+	var scanErr error
+	for _, c := range metadata.localCommits(repo) {
+		if c.NumParents() > 1 {
+			continue
+		}
+
+		found, err := scanCommit(c, rules)
+		if err != nil {
+			// keep the findings of the other commits
+			failed = append(failed, c.Hash.String())
+			if scanErr == nil {
+				scanErr = err
+			}
+			continue
+		}
+
+		author := Metadata{Email: c.Author.Email, Name: c.Author.Name}
+		if p, ok := metadata[author]; ok && p.canonical != nil {
+			author = *p.canonical
+		}
+		for _, secret := range found {
+			secret.Author = author.Name
+			secret.Email = author.Email
+		}
+		secrets = append(secrets, found...)
+	}
+
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return secrets[i].Path < secrets[j].Path
+	})
+	if len(failed) != 0 {
+		return secrets, fmt.Errorf("failed to scan %d commits, first '%s': (%s)",
+			len(failed), failed[0], scanErr.Error())
+	}
+	return secrets, nil
+}
+
+func scanCommit(c *object.Commit, rules []*Rule) ([]*Secret, error) {
+	changes, err := commitChanges(c)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := changes.Patch()
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []*Secret
+	for _, fp := range patch.FilePatches() {
+		_, to := fp.Files()
+		if fp.IsBinary() || to == nil {
+			continue
+		}
+		for _, chunk := range fp.Chunks() {
+			if chunk.Type() != diff.Add {
+				continue
+			}
+			for _, line := range strings.Split(chunk.Content(), "\n") {
+				var found []*Secret
+				var values []string
+				for _, rule := range rules {
+					for _, secret := range rule.match(line) {
+						found = append(found, &Secret{Rule: rule.ID, Path: to.Path(), Commit: c.Hash.String()})
+						values = append(values, secret)
+					}
+				}
+				// every secret of the line is redacted in every preview
+				for i, secret := range found {
+					secret.Preview = preview(line, values[i], values)
+					secrets = append(secrets, secret)
+				}
+			}
+		}
+	}
+	return secrets, nil
+}
+
+// match returns the secrets the rule finds in the line.
+func (r *Rule) match(line string) []string {
+	var secrets []string
+	for _, m := range r.re.FindAllStringSubmatch(line, -1) {
+		secret := m[0]
+		if len(m) > 1 && m[1] != "" {
+			secret = m[1]
+		}
+		if r.Entropy > 0 && entropy(secret) < r.Entropy {
+			continue
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+
+	var h float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// preview returns the line with all its secrets redacted, shortened around
+// the secret if the line is too long. Lengths are counted in runes.
+func preview(line, secret string, secrets []string) string {
+	line = strings.TrimSpace(line)
+
+	// longer secrets first, so that secrets containing others are
+	// redacted whole
+	sorted := append([]string(nil), secrets...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, v := range sorted {
+		line = strings.ReplaceAll(line, v, redact(v))
+	}
+
+	redacted := redact(secret)
+	i := strings.Index(line, redacted)
+	if i < 0 {
+		return redacted
+	}
+
+	runes := []rune(line)
+	if len(runes) > maxPreviewLength {
+		start := utf8.RuneCountInString(line[:i]) - (maxPreviewLength-utf8.RuneCountInString(redacted))/2
+		if start < 0 {
+			start = 0
+		}
+		end := start + maxPreviewLength
+		if end > len(runes) {
+			end = len(runes)
+			start = end - maxPreviewLength
+		}
+		runes = runes[start:end]
+	}
+	return string(runes)
+}
+
+// redact keeps the first characters of a secret and masks the rest.
+func redact(secret string) string {
+	runes := []rune(secret)
+	keep := len(runes) / 5
+	if keep > 4 {
+		keep = 4
+	}
+	return string(runes[:keep]) + strings.Repeat("*", 8)
+}
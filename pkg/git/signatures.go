@@ -0,0 +1,256 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Signature key types.
+const (
+	KeyTypePGP = "pgp"
+	KeyTypeSSH = "ssh"
+)
+
+const (
+	beginSSHSignature = "-----BEGIN SSH SIGNATURE-----"
+	beginPGPSignature = "-----BEGIN PGP SIGNATURE-----"
+)
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// parseSignature extracts the signing key and the embedded signer user IDs
+// from an armored PGP or SSH signature. It returns nil for unsupported
+// signature formats such as X.509.
+func parseSignature(armored string) (*SigningKey, error) {
+	switch {
+	case strings.Contains(armored, beginPGPSignature):
+		data, err := dearmor(armored)
+		if err != nil {
+			return nil, err
+		}
+		return parsePGPSignature(data)
+	case strings.Contains(armored, beginSSHSignature):
+		data, err := dearmor(armored)
+		if err != nil {
+			return nil, err
+		}
+		return parseSSHSignature(data)
+	}
+	return nil, nil
+}
+
+// tagSignature returns the armored signature of the tag. go-git only
+// separates PGP signatures from the tag message, so SSH signatures are
+// looked up in the message.
+func tagSignature(pgpSignature, message string) string {
+	if pgpSignature != "" {
+		return pgpSignature
+	}
+	i := strings.Index(message, beginSSHSignature)
+	if i < 0 {
+		return ""
+	}
+	return message[i:]
+}
+
+// dearmor decodes the base64 body of an ASCII armored block, skipping armor
+// headers and the CRC24 checksum.
+func dearmor(armored string) ([]byte, error) {
+	var body strings.Builder
+	var inBlock, inBody bool
+	for _, line := range strings.Split(armored, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "-----BEGIN"):
+			inBlock = true
+		case strings.HasPrefix(line, "-----END"):
+			return base64.StdEncoding.DecodeString(body.String())
+		case !inBlock:
+		case !inBody:
+			// armor headers ("Version: ...") end with an empty line, SSH
+			// signatures have no headers at all
+			if line == "" {
+				inBody = true
+			} else if !strings.Contains(line, ": ") {
+				inBody = true
+				body.WriteString(line)
+			}
+		case strings.HasPrefix(line, "="):
+		default:
+			body.WriteString(line)
+		}
+	}
+	return nil, errInvalidSignature
+}
+
+// parsePGPSignature reads the issuer key ID, issuer fingerprint and signer
+// user ID subpackets of an OpenPGP signature packet (RFC 4880, section 5.2).
+func parsePGPSignature(data []byte) (*SigningKey, error) {
+	body, err := pgpPacketBody(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{Type: KeyTypePGP}
+	switch body[0] {
+	case 3:
+		if len(body) < 15 {
+			return nil, errInvalidSignature
+		}
+		key.ID = strings.ToUpper(hex.EncodeToString(body[7:15]))
+	case 4, 5:
+		// version, type, public key and hash algorithms
+		if len(body) < 4 {
+			return nil, errInvalidSignature
+		}
+		rest := body[4:]
+		// hashed and unhashed subpacket areas
+		for i := 0; i < 2; i++ {
+			if len(rest) < 2 {
+				return nil, errInvalidSignature
+			}
+			n := int(binary.BigEndian.Uint16(rest))
+			if n > len(rest)-2 {
+				return nil, errInvalidSignature
+			}
+			if err := parsePGPSubpackets(rest[2:2+n], key); err != nil {
+				return nil, err
+			}
+			rest = rest[2+n:]
+		}
+	default:
+		return nil, errInvalidSignature
+	}
+
+	if key.ID == "" && len(key.Fingerprint) >= 16 {
+		key.ID = key.Fingerprint[len(key.Fingerprint)-16:]
+	}
+	if key.ID == "" {
+		return nil, errInvalidSignature
+	}
+	return key, nil
+}
+
+// pgpPacketBody returns the body of the first packet if it is a signature.
+func pgpPacketBody(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return nil, errInvalidSignature
+	}
+
+	var tag byte
+	var length, offset int
+	if data[0]&0x40 != 0 {
+		// new format
+		tag = data[0] & 0x3f
+		switch o := int(data[1]); {
+		case o < 192:
+			length, offset = o, 2
+		case o < 224:
+			if len(data) < 3 {
+				return nil, errInvalidSignature
+			}
+			length, offset = (o-192)<<8+int(data[2])+192, 3
+		case o == 255:
+			if len(data) < 6 {
+				return nil, errInvalidSignature
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[2:6])), 6
+		default:
+			// partial body lengths are not used for signatures
+			return nil, errInvalidSignature
+		}
+	} else {
+		// old format
+		tag = (data[0] & 0x3c) >> 2
+		switch data[0] & 0x03 {
+		case 0:
+			length, offset = int(data[1]), 2
+		case 1:
+			if len(data) < 3 {
+				return nil, errInvalidSignature
+			}
+			length, offset = int(binary.BigEndian.Uint16(data[1:3])), 3
+		case 2:
+			if len(data) < 5 {
+				return nil, errInvalidSignature
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[1:5])), 5
+		default:
+			length, offset = len(data)-1, 1
+		}
+	}
+
+	// lengths are compared without adding to the offset, which could
+	// overflow for four-octet lengths
+	if tag != 2 || length < 1 || length > len(data)-offset {
+		return nil, errInvalidSignature
+	}
+	return data[offset : offset+length], nil
+}
+
+func parsePGPSubpackets(data []byte, key *SigningKey) error {
+	for len(data) > 0 {
+		var length, offset int
+		switch o := int(data[0]); {
+		case o < 192:
+			length, offset = o, 1
+		case o < 255:
+			if len(data) < 2 {
+				return errInvalidSignature
+			}
+			length, offset = (o-192)<<8+int(data[1])+192, 2
+		default:
+			if len(data) < 5 {
+				return errInvalidSignature
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[1:5])), 5
+		}
+		if length < 1 || length > len(data)-offset {
+			return errInvalidSignature
+		}
+
+		packet := data[offset : offset+length]
+		switch packet[0] & 0x7f {
+		case 16: // issuer key ID
+			if len(packet) == 9 {
+				key.ID = strings.ToUpper(hex.EncodeToString(packet[1:]))
+			}
+		case 33: // issuer fingerprint, prefixed with the key version
+			if len(packet) > 2 {
+				key.Fingerprint = strings.ToUpper(hex.EncodeToString(packet[2:]))
+			}
+		case 28: // signer's user ID
+			key.UserIDs = append(key.UserIDs, string(packet[1:]))
+		}
+		data = data[offset+length:]
+	}
+	return nil
+}
+
+// parseSSHSignature reads the public key of an SSH signature (SSHSIG
+// format) and returns its SHA256 fingerprint.
+func parseSSHSignature(data []byte) (*SigningKey, error) {
+	if !bytes.HasPrefix(data, []byte("SSHSIG")) || len(data) < 10 {
+		return nil, errInvalidSignature
+	}
+	// magic preamble and version
+	data = data[10:]
+	if len(data) < 4 {
+		return nil, errInvalidSignature
+	}
+	n := int(binary.BigEndian.Uint32(data))
+	if n < 0 || n > len(data)-4 {
+		return nil, errInvalidSignature
+	}
+
+	sum := sha256.Sum256(data[4 : 4+n])
+	return &SigningKey{
+		Type: KeyTypeSSH,
+		ID:   "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+	}, nil
+}
@@ -0,0 +1,79 @@
+package git
+
+import (
+	"testing"
+)
+
+// signaturePacket wraps the body in a new format signature packet.
+func signaturePacket(body []byte) []byte {
+	return append([]byte{0xc2, byte(len(body))}, body...)
+}
+
+func TestParsePGPSignature(t *testing.T) {
+	issuer := []byte{9, 16, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	valid := append([]byte{4, 0, 1, 8, 0, 0, 0, byte(len(issuer))}, issuer...)
+
+	key, err := parsePGPSignature(signaturePacket(valid))
+	if err != nil {
+		t.Fatalf("valid signature: %v", err)
+	}
+	if key.ID != "0123456789ABCDEF" {
+		t.Errorf("key ID = %q, want %q", key.ID, "0123456789ABCDEF")
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated header", []byte{0xc2}},
+		{"truncated v4 body", []byte{0xc2, 0x01, 0x04}},
+		{"truncated v5 body", []byte{0xc2, 0x03, 0x05, 0x00, 0x01}},
+		{"truncated v3 body", signaturePacket([]byte{3, 5, 0, 0, 0, 0, 0})},
+		{"body longer than data", []byte{0xc2, 0x10, 0x04, 0x00}},
+		{"four-octet body length", []byte{0xc2, 0xff, 0xff, 0xff, 0xff, 0xff, 0x04}},
+		{"old format four-octet length", []byte{0x8a, 0xff, 0xff, 0xff, 0xff, 0x04}},
+		{"missing subpacket area length", signaturePacket([]byte{4, 0, 1, 8, 0})},
+		{"hashed area beyond body", signaturePacket([]byte{4, 0, 1, 8, 0, 9, 16})},
+		{"missing unhashed area", signaturePacket([]byte{4, 0, 1, 8, 0, 0})},
+		{"subpacket beyond area", signaturePacket([]byte{4, 0, 1, 8, 0, 0, 0, 3, 9, 16, 1})},
+		{"zero subpacket length", signaturePacket([]byte{4, 0, 1, 8, 0, 0, 0, 1, 0})},
+		{"truncated two-octet subpacket length", signaturePacket([]byte{4, 0, 1, 8, 0, 0, 0, 1, 200})},
+		{"truncated four-octet subpacket length", signaturePacket([]byte{4, 0, 1, 8, 0, 0, 0, 3, 255, 0xff, 0xff})},
+		{"four-octet subpacket length", signaturePacket([]byte{4, 0, 1, 8, 0, 0, 0, 6, 255, 0xff, 0xff, 0xff, 0xff, 16})},
+		{"no issuer", signaturePacket([]byte{4, 0, 1, 8, 0, 0, 0, 0})},
+		{"unknown version", signaturePacket([]byte{6, 0, 1, 8})},
+		{"not a signature", []byte{0xc6, 0x01, 0x04}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := parsePGPSignature(test.data)
+			if err != errInvalidSignature {
+				t.Errorf("err = %v, want %v", err, errInvalidSignature)
+			}
+			if key != nil {
+				t.Errorf("key = %+v, want nil", key)
+			}
+		})
+	}
+}
+
+func TestParseSSHSignature(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no magic", []byte("SSHSIX\x00\x00\x00\x01")},
+		{"truncated version", []byte("SSHSIG\x00\x00")},
+		{"missing key length", []byte("SSHSIG\x00\x00\x00\x01\x00")},
+		{"key beyond data", []byte("SSHSIG\x00\x00\x00\x01\x00\x00\x00\x05ab")},
+		{"four-octet key length", []byte("SSHSIG\x00\x00\x00\x01\xff\xff\xff\xffab")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseSSHSignature(test.data); err != errInvalidSignature {
+				t.Errorf("err = %v, want %v", err, errInvalidSignature)
+			}
+		})
+	}
+}
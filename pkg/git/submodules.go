@@ -0,0 +1,140 @@
+package git
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const gitmodulesFile = ".gitmodules"
+
+// SubmoduleURLs returns the URLs of the submodules declared in .gitmodules
+// of the HEAD tree. Relative URLs are resolved against parentURL.
+func SubmoduleURLs(repo *git.Repository, parentURL string) ([]string, error) {
+	head, err := repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := commit.File(gitmodulesFile)
+	if err == object.ErrFileNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	modules := config.NewModules()
+	if err := modules.Unmarshal([]byte(contents)); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, submodule := range modules.Submodules {
+		if submodule.URL == "" {
+			continue
+		}
+		urls = appendUnique(urls, resolveURL(parentURL, submodule.URL))
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+// resolveURL resolves a relative submodule URL ("./x" or "../x") against the
+// URL of the superproject, as git does.
+func resolveURL(base, rel string) string {
+	if !strings.HasPrefix(rel, "./") && !strings.HasPrefix(rel, "../") {
+		return rel
+	}
+
+	base = strings.TrimSuffix(base, "/")
+	for {
+		if strings.HasPrefix(rel, "./") {
+			rel = rel[2:]
+		} else if strings.HasPrefix(rel, "../") {
+			rel = rel[3:]
+			if i := strings.LastIndexAny(base, "/:"); i >= 0 && !strings.HasSuffix(base[:i+1], "://") {
+				if base[i] == ':' {
+					// keep the separator of scp-like URLs
+					base = base[:i+1]
+				} else {
+					base = base[:i]
+				}
+			}
+		} else {
+			break
+		}
+	}
+
+	if strings.HasSuffix(base, ":") {
+		return base + rel
+	}
+	return base + "/" + rel
+}
+
+// normalizeURL returns the key used to deduplicate repository URLs.
+func normalizeURL(url string) string {
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	return strings.ToLower(url)
+}
+
+// CloneReposRecursive clones the repositories and, up to the given depth,
+// the submodules they declare. Every repository is cloned once; results of
+// submodules carry the URL of the repository that declared them.
+func CloneReposRecursive(ctx context.Context, urls []string, threads, depth int) <-chan OpenResult {
+	resultCh := make(chan OpenResult)
+
+	go func() {
+		defer close(resultCh)
+		seen := make(map[string]struct{})
+		parents := make(map[string]string)
+
+		var wave []string
+		for _, url := range urls {
+			if _, ok := seen[normalizeURL(url)]; !ok {
+				seen[normalizeURL(url)] = struct{}{}
+				wave = append(wave, url)
+			}
+		}
+
+		for level := 0; len(wave) > 0; level++ {
+			var next []string
+			for result := range CloneRepos(ctx, wave, threads) {
+				result.Parent = parents[result.Origin]
+				if result.Error == nil && level < depth {
+					submodules, err := SubmoduleURLs(result.Repo, result.Origin)
+					result.SubmoduleError = err
+					for _, url := range submodules {
+						if _, ok := seen[normalizeURL(url)]; !ok {
+							seen[normalizeURL(url)] = struct{}{}
+							parents[url] = result.Origin
+							next = append(next, url)
+						}
+					}
+				}
+				resultCh <- result
+			}
+			wave = next
+		}
+	}()
+
+	return resultCh
+}
@@ -0,0 +1,35 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+// trailerRe matches identity trailers such as "Signed-off-by: Name <email>".
+var trailerRe = regexp.MustCompile(
+	`(?i)^\s*(co-authored-by|signed-off-by|reviewed-by|acked-by|reported-by)\s*:\s*(.*?)\s*<([^<>]*)>\s*$`)
+
+type trailer struct {
+	Role  string
+	Name  string
+	Email string
+}
+
+// parseTrailers returns identities found in the commit message trailers.
+// Trailers are matched on any line of the message, since squashed and
+// rewritten commits often carry them outside of the last paragraph.
+func parseTrailers(message string) []trailer {
+	var trailers []trailer
+	for _, line := range strings.Split(message, "\n") {
+		m := trailerRe.FindStringSubmatch(line)
+		if m == nil || (m[2] == "" && m[3] == "") {
+			continue
+		}
+		trailers = append(trailers, trailer{
+			Role:  strings.ToLower(m[1]),
+			Name:  m[2],
+			Email: m[3],
+		})
+	}
+	return trailers
+}
@@ -1,24 +1,149 @@
 package git
 
-import "github.com/go-git/go-git/v5"
+import (
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
 
 const (
 	remoteName    = "origin"
 	tempDirPrefix = "gitrecon*"
 )
 
-type CommitMetadata map[Metadata]struct{}
+// Roles in which an identity can be seen.
+const (
+	RoleAuthor    = "author"
+	RoleCommitter = "committer"
+	RoleCoAuthor  = "co-authored-by"
+	RoleSignedOff = "signed-off-by"
+	RoleReviewer  = "reviewed-by"
+	RoleAcker     = "acked-by"
+	RoleReporter  = "reported-by"
+	RoleTagger    = "tagger"
+	RoleReflog    = "reflog"
+	RoleFile      = "file"
+)
+
+type CommitMetadata map[Metadata]*Provenance
 
 type Metadata struct {
 	Email string
 	Name  string
 }
 
+// Provenance records where and when an identity was seen.
+type Provenance struct {
+	roles     map[string]struct{}
+	commits   map[string]struct{}
+	refs      map[string]struct{}
+	tags      map[string]struct{}
+	files     map[string]struct{}
+	pulls     map[int]struct{}
+	keys      map[string]*SigningKey
+	offsets   map[string]*TimezoneUsage
+	hours     [7][24]int
+	canonical *Metadata
+	artifacts *artifactSet
+	// reachable is set if the identity was seen in objects reachable from
+	// branches, remotes or tags; unreachable holds the commits it was seen
+	// in that are not.
+	reachable   bool
+	unreachable map[string]struct{}
+	firstSeen   time.Time
+	lastSeen    time.Time
+}
+
+// Identity is the output form of a single identity and its provenance.
+type Identity struct {
+	Email     string   `json:"email"`
+	Name      string   `json:"name"`
+	Canonical string   `json:"canonical,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Commits   []string `json:"commits,omitempty"`
+	Refs      []string `json:"refs,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	// PullRequests are the numbers of the pull requests the identity was
+	// seen in, for commits not on any branch.
+	PullRequests []int    `json:"pull_requests,omitempty"`
+	Keys         []string `json:"keys,omitempty"`
+	Signers      []string `json:"signers,omitempty"`
+	// Files lists the repository files the identity was found in.
+	Files     []string   `json:"files,omitempty"`
+	FirstSeen *time.Time `json:"first_seen,omitempty"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+	Activity  *Activity  `json:"activity,omitempty"`
+	// Artifacts are found in the messages of commits the identity
+	// authored.
+	Artifacts *Artifacts `json:"artifacts,omitempty"`
+	// Unreachable is set if the identity was only seen in commits and
+	// reflog entries that no branch, remote or tag reaches.
+	Unreachable        bool     `json:"unreachable,omitempty"`
+	UnreachableCommits []string `json:"unreachable_commits,omitempty"`
+}
+
+// Activity is the temporal profile of an identity built from its own
+// author, committer and tagger signatures, one per commit. Unlike
+// first_seen and last_seen, it leaves out trailer mentions and reflog
+// entries.
+type Activity struct {
+	// Timezones lists the UTC offsets in order of first use.
+	Timezones []*TimezoneUsage `json:"timezones"`
+	// Hours is the hour-of-week histogram in the signer's local time,
+	// indexed by weekday (Sunday first) and hour.
+	Hours [7][24]int `json:"hours"`
+	// Overlapping is set when offsets more than an hour apart were used
+	// during overlapping periods, which may indicate a shared account.
+	Overlapping bool `json:"overlapping,omitempty"`
+}
+
+// TimezoneUsage describes how often and when a UTC offset was used.
+type TimezoneUsage struct {
+	Offset string    `json:"offset"`
+	Count  int       `json:"count"`
+	First  time.Time `json:"first"`
+	Last   time.Time `json:"last"`
+}
+
+// Tag describes a tag reference. Tagger and date are set for annotated tags.
+type Tag struct {
+	Name   string     `json:"name"`
+	Target string     `json:"target"`
+	Tagger string     `json:"tagger,omitempty"`
+	Email  string     `json:"email,omitempty"`
+	Date   *time.Time `json:"date,omitempty"`
+}
+
+// Cluster is a group of names and emails that likely belong to one person.
+type Cluster struct {
+	Names      []string `json:"names,omitempty"`
+	Emails     []string `json:"emails,omitempty"`
+	Confidence float64  `json:"confidence"`
+}
+
+// SigningKey describes a key commits or tags were signed with. ID is the
+// PGP key ID or the SHA256 fingerprint of the SSH key.
+type SigningKey struct {
+	Type        string   `json:"type"`
+	ID          string   `json:"id"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+	UserIDs     []string `json:"user_ids,omitempty"`
+	Identities  []string `json:"identities,omitempty"`
+}
+
 type OpenResult struct {
 	Origin string
+	Root   string
+	Parent string
 	Repo   *git.Repository
 	Dir    string
-	Error  error
+	// TempDir is the temporary directory to remove once the repository
+	// is analysed. It is empty for repositories opened in place.
+	TempDir string
+	Error   error
+	// SubmoduleError is set if submodules of the repository could not be
+	// read. The repository itself is still usable.
+	SubmoduleError error
 }
 
 type AnalyseResult struct {
@@ -35,8 +35,7 @@ func NewClient(token, baseURL, uploadURL string) (*Client, error) {
 	return &Client{client: c}, nil
 }
 
-func (c Client) GetUserOrOrganization(name string) (*github.User, error) {
-	ctx := context.Background()
+func (c Client) GetUserOrOrganization(ctx context.Context, name string) (*github.User, error) {
 	user, _, err := c.client.Users.Get(ctx, name)
 	if err != nil {
 		return nil, err
@@ -45,9 +44,8 @@ func (c Client) GetUserOrOrganization(name string) (*github.User, error) {
 	return user, nil
 }
 
-func (c Client) GetUserOrganizations(name string) ([]*github.Organization,
+func (c Client) GetUserOrganizations(ctx context.Context, name string) ([]*github.Organization,
 	*github.Response, error) {
-	ctx := context.Background()
 	options := &github.ListOptions{PerPage: 100}
 	var orgs []*github.Organization
 
@@ -68,8 +66,7 @@ func (c Client) GetUserOrganizations(name string) ([]*github.Organization,
 	return orgs, nil, nil
 }
 
-func (c Client) GetRepository(owner, repo string) (*github.Repository, *github.Response, error) {
-	ctx := context.Background()
+func (c Client) GetRepository(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
 	repository, resp, err := c.client.Repositories.Get(ctx, owner, repo)
 	if err != nil {
 		return nil, resp, err
@@ -78,9 +75,8 @@ func (c Client) GetRepository(owner, repo string) (*github.Repository, *github.R
 	return repository, nil, nil
 }
 
-func (c Client) ListOrganizationMembers(org string) ([]*github.User,
+func (c Client) ListOrganizationMembers(ctx context.Context, org string) ([]*github.User,
 	*github.Response, error) {
-	ctx := context.Background()
 	opts := &github.ListMembersOptions{
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
@@ -103,9 +99,8 @@ func (c Client) ListOrganizationMembers(org string) ([]*github.User,
 	return users, nil, nil
 }
 
-func (c Client) ListRepositories(user, userType string, includeForks bool) ([]*github.Repository,
+func (c Client) ListRepositories(ctx context.Context, user, userType string, includeForks bool) ([]*github.Repository,
 	*github.Response, error) {
-	ctx := context.Background()
 	options := github.ListOptions{PerPage: 100}
 	optUser := &github.RepositoryListOptions{
 		Type:        "all",
@@ -151,8 +146,7 @@ func (c Client) ListRepositories(user, userType string, includeForks bool) ([]*g
 	return allRepos, nil, nil
 }
 
-func (c Client) ListPullRequests(repo *github.Repository, count int) ([]*github.PullRequest, *github.Response, error) {
-	ctx := context.Background()
+func (c Client) ListPullRequests(ctx context.Context, repo *github.Repository, count int) ([]*github.PullRequest, *github.Response, error) {
 	opts := &github.PullRequestListOptions{
 		State:       "all",
 		ListOptions: github.ListOptions{PerPage: 100},
@@ -181,25 +175,66 @@ func (c Client) ListPullRequests(repo *github.Repository, count int) ([]*github.
 	return allPulls, nil, nil
 }
 
-// CreateRepository creates private Github repository with random name.
-func (c Client) CreateRepository() (*github.Repository, error) {
-	ctx := context.Background()
+// ListForks lists the forks of the repository, newest first. Zero count
+// lists all of them.
+func (c Client) ListForks(ctx context.Context, repo *github.Repository, count int) ([]*github.Repository, *github.Response, error) {
+	opts := &github.RepositoryListForksOptions{
+		Sort:        "newest",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var allForks []*github.Repository
+	for {
+		forks, resp, err := c.client.Repositories.ListForks(ctx, *repo.Owner.Login, *repo.Name, opts)
+		if err != nil {
+			return allForks, resp, err
+		}
+
+		allForks = append(allForks, forks...)
+
+		if count != 0 && len(allForks) >= count {
+			allForks = allForks[:count]
+			break
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opts.Page = resp.NextPage
+	}
+
+	return allForks, nil, nil
+}
+
+// ScratchDescription marks the repositories created by CreateRepository, so
+// that the ones left behind by interrupted runs can be found and deleted.
+const ScratchDescription = "gitosint scratch repository"
+
+// CreateRepository creates private Github repository with random name owned
+// by the organization, or by the authenticated user if org is empty.
+func (c Client) CreateRepository(ctx context.Context, org string) (*github.Repository, error) {
 	githubRepo := &github.Repository{
-		Name:    github.String(uuid.NewString()),
-		Private: github.Bool(true),
+		Name:        github.String(uuid.NewString()),
+		Private:     github.Bool(true),
+		Description: github.String(ScratchDescription),
 	}
-	createdGithubRepo, _, err := c.client.Repositories.Create(ctx, "", githubRepo)
+	createdGithubRepo, _, err := c.client.Repositories.Create(ctx, org, githubRepo)
 	if err != nil {
 		return nil, err
 	}
 	// back-off as recommended, 10 retries
 	var sec time.Duration = 1
 	for i := 0; i < 10; i++ {
-		createdGithubRepo, _, err = c.client.Repositories.GetByID(ctx, *createdGithubRepo.ID)
+		_, _, err = c.client.Repositories.GetByID(ctx, *createdGithubRepo.ID)
 		if err == nil {
 			break
 		}
-		time.Sleep(sec * time.Second)
+		if err := wait(ctx, sec*time.Second); err != nil {
+			// the caller never sees the repository, delete it here
+			c.DeleteRepository(context.Background(), createdGithubRepo)
+			return nil, err
+		}
 		sec *= 2
 	}
 
@@ -207,45 +242,43 @@ func (c Client) CreateRepository() (*github.Repository, error) {
 }
 
 // DeleteRepository deletes user's Github repository.
-func (c Client) DeleteRepository(repo *github.Repository) error {
-	ctx := context.Background()
+func (c Client) DeleteRepository(ctx context.Context, repo *github.Repository) error {
 	_, err := c.client.Repositories.Delete(ctx, *repo.Owner.Login, *repo.Name)
 	return err
 }
 
-// ListContributors returns repository contributors (max 500)
-func (c Client) ListContributors(repo *github.Repository) ([]*github.Contributor, *github.Response, error) {
-	ctx := context.Background()
-	opts := &github.ListContributorsOptions{
-		Anon:        "",
-		ListOptions: github.ListOptions{PerPage: 100},
+// ListScratchRepositories lists the repositories marked with
+// ScratchDescription that the owner owns. The owner is the authenticated
+// user if ownerType is "User", otherwise an organization.
+func (c Client) ListScratchRepositories(ctx context.Context, owner, ownerType string) ([]*github.Repository,
+	*github.Response, error) {
+	// private repositories of the authenticated user are only listed
+	// without a user name
+	user := owner
+	if ownerType == "User" {
+		user = ""
+	}
+	repos, resp, err := c.ListRepositories(ctx, user, ownerType, true)
+	if err != nil {
+		return nil, resp, err
 	}
 
-	var contributorsFinal []*github.Contributor
-	for {
-		contributors, resp, err := c.client.Repositories.ListContributors(ctx, *repo.Owner.Login, *repo.Name, opts)
-		if err != nil {
-			return contributorsFinal, resp, err
+	var scratch []*github.Repository
+	for _, repo := range repos {
+		if repo.GetDescription() == ScratchDescription && strings.EqualFold(repo.GetOwner().GetLogin(), owner) {
+			scratch = append(scratch, repo)
 		}
-
-		contributorsFinal = append(contributorsFinal, contributors...)
-
-		if resp.NextPage == 0 {
-			break
-		}
-
-		opts.Page = resp.NextPage
 	}
-
-	return contributorsFinal, nil, nil
+	return scratch, nil, nil
 }
 
-// ListEmails returns emails of a repository contributor
-func (c Client) ListEmails(repo *github.Repository, contributor *github.Contributor) ([]string,
+// ListCommitAuthors maps the author email of every commit of the repository
+// to the GitHub account the commit is linked to. Emails of commits that are
+// not linked to an account map to nil.
+func (c Client) ListCommitAuthors(ctx context.Context, repo *github.Repository) (map[string]*github.User,
 	*github.Response, error) {
-	ctx := context.Background()
-	emailsMap := make(map[string]struct{})
-	opts := &github.CommitsListOptions{Author: *contributor.Login, ListOptions: github.ListOptions{PerPage: 100}}
+	authors := make(map[string]*github.User)
+	opts := &github.CommitsListOptions{ListOptions: github.ListOptions{PerPage: 100}}
 
 	for {
 		commits, resp, err := c.client.Repositories.ListCommits(ctx, *repo.Owner.Login, *repo.Name, opts)
@@ -253,12 +286,11 @@ func (c Client) ListEmails(repo *github.Repository, contributor *github.Contribu
 			return nil, resp, err
 		}
 
-		if len(commits) == 0 {
-			break
-		}
-
-		if commits[0].Commit.Author.Email != nil {
-			emailsMap[*commits[0].Commit.Author.Email] = struct{}{}
+		for _, commit := range commits {
+			email := commit.GetCommit().GetAuthor().GetEmail()
+			if _, ok := authors[email]; !ok || commit.Author != nil {
+				authors[email] = commit.Author
+			}
 		}
 
 		if resp.NextPage == 0 {
@@ -268,30 +300,27 @@ func (c Client) ListEmails(repo *github.Repository, contributor *github.Contribu
 		opts.Page = resp.NextPage
 	}
 
-	emails := make([]string, 0, len(emailsMap))
-	for k := range emailsMap {
-		emails = append(emails, k)
-	}
-	return emails, nil, nil
+	return authors, nil, nil
 }
 
-func (c Client) searchCommits(query string, opts github.SearchOptions) (
+func (c Client) searchCommits(ctx context.Context, query string, opts github.SearchOptions) (
 	*github.CommitsSearchResult, *github.Response, error) {
-	ctx := context.Background()
 	commits := github.CommitsSearchResult{}
 	for {
 		csr, resp, err := c.client.Search.Commits(ctx, query, &opts)
 		if err != nil {
 			if _, ok := err.(*github.RateLimitError); ok {
 				limits, _, _ := c.client.RateLimits(ctx) // dont care about error
-				if limits.GetCore().Remaining == 0 {
+				if limits.GetCore() == nil || limits.GetCore().Remaining == 0 {
 					// hitting global rate limit
 					return &commits, resp, err
 				}
 				currentTime := time.Now().UTC().Unix()
 				delta := int64(math.Abs(float64(resp.Rate.Reset.UTC().Unix() - currentTime)))
 				// Search rate limit hit
-				time.Sleep(time.Duration(delta+1) * time.Second)
+				if err := wait(ctx, time.Duration(delta+1)*time.Second); err != nil {
+					return &commits, resp, err
+				}
 				continue
 			}
 			return &commits, resp, err
@@ -310,9 +339,8 @@ func (c Client) searchCommits(query string, opts github.SearchOptions) (
 	return &commits, nil, nil
 }
 
-func (c Client) searchPullRequests(query string, opts github.SearchOptions) (
+func (c Client) searchPullRequests(ctx context.Context, query string, opts github.SearchOptions) (
 	*github.IssuesSearchResult, *github.Response, error) {
-	ctx := context.Background()
 	pulls := github.IssuesSearchResult{}
 	query += " is:pr"
 	for {
@@ -320,13 +348,15 @@ func (c Client) searchPullRequests(query string, opts github.SearchOptions) (
 		if err != nil {
 			if _, ok := err.(*github.RateLimitError); ok {
 				limits, _, _ := c.client.RateLimits(ctx)
-				if limits.GetCore().Remaining == 0 {
+				if limits.GetCore() == nil || limits.GetCore().Remaining == 0 {
 					return &pulls, resp, err
 				}
 				currentTime := time.Now().UTC().Unix()
 				delta := int64(math.Abs(float64(resp.Rate.Reset.UTC().Unix() - currentTime)))
 				//Search rate limit hit
-				time.Sleep(time.Duration(delta+1) * time.Second)
+				if err := wait(ctx, time.Duration(delta+1)*time.Second); err != nil {
+					return &pulls, resp, err
+				}
 				continue
 			}
 			return &pulls, resp, err
@@ -345,9 +375,8 @@ func (c Client) searchPullRequests(query string, opts github.SearchOptions) (
 	return &pulls, nil, nil
 }
 
-func (c Client) ListCommitsOnPullRequest(repo *github.Repository, number int) (
+func (c Client) ListCommitsOnPullRequest(ctx context.Context, repo *github.Repository, number int) (
 	[]*github.CommitResult, *github.Response, error) {
-	ctx := context.Background()
 	opts := &github.ListOptions{PerPage: 100}
 	var commits []*github.CommitResult
 
@@ -381,9 +410,8 @@ func (c Client) ListCommitsOnPullRequest(repo *github.Repository, number int) (
 	return commits, nil, nil
 }
 
-func (c Client) ListCommits(repo *github.Repository, author string, opts github.ListOptions) (
+func (c Client) ListCommits(ctx context.Context, repo *github.Repository, author string, opts github.ListOptions) (
 	[]*github.RepositoryCommit, *github.Response, error) {
-	ctx := context.Background()
 	options := github.CommitsListOptions{
 		Author:      author,
 		ListOptions: opts,
@@ -406,7 +434,7 @@ func (c Client) ListCommits(repo *github.Repository, author string, opts github.
 }
 
 // SearchCommits searches user commits and pull requests across Github
-func (c Client) SearchCommits(loginOrEmail string, searchType string) ([]*github.CommitResult,
+func (c Client) SearchCommits(ctx context.Context, loginOrEmail string, searchType string) ([]*github.CommitResult,
 	*github.Response, error) {
 	opts := github.SearchOptions{
 		ListOptions: github.ListOptions{PerPage: 100},
@@ -434,7 +462,7 @@ out:
 		for _, order := range orderOpts {
 			opts.Sort = cs
 			opts.Order = order
-			csr, resp, err := c.searchCommits(query, opts)
+			csr, resp, err := c.searchCommits(ctx, query, opts)
 			if err != nil {
 				return commits, resp, err
 			}
@@ -451,13 +479,12 @@ out:
 	}
 
 	repos := make(map[string]*github.Repository)
-	ctx := context.Background()
 out2:
 	for _, cs := range sortOpts {
 		for _, order := range orderOpts {
 			opts.Sort = cs
 			opts.Order = order
-			isr, resp, err := c.searchPullRequests(query, opts)
+			isr, resp, err := c.searchPullRequests(ctx, query, opts)
 			if err != nil {
 				return commits, resp, err
 			}
@@ -472,7 +499,7 @@ out2:
 					}
 				}
 				repos[*pr.RepositoryURL] = repo
-				cr, resp, err := c.ListCommitsOnPullRequest(repo, *pr.Number)
+				cr, resp, err := c.ListCommitsOnPullRequest(ctx, repo, *pr.Number)
 				if err != nil {
 					return commits, resp, err
 				}
@@ -492,18 +519,27 @@ out2:
 	return commits, nil, nil
 }
 
-func (c Client) RateLimits() (*github.RateLimits, *github.Response, error) {
-	ctx := context.Background()
+func (c Client) RateLimits(ctx context.Context) (*github.RateLimits, *github.Response, error) {
 	return c.client.RateLimits(ctx)
 }
 
-func (c Client) IsGlobalRateLimitExceeded(err error) bool {
+func (c Client) IsGlobalRateLimitExceeded(ctx context.Context, err error) bool {
 	if _, ok := err.(*github.RateLimitError); ok {
-		limits, _, _ := c.client.RateLimits(context.Background())
-		if limits.GetCore().Remaining == 0 {
+		limits, _, _ := c.client.RateLimits(ctx)
+		if limits.GetCore() != nil && limits.GetCore().Remaining == 0 {
 			return true
 		}
 	}
 
 	return false
 }
+
+// wait sleeps for the duration or until the context is cancelled.
+func wait(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}